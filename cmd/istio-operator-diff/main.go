@@ -0,0 +1,114 @@
+// Command istio-operator-diff renders an SMCP's charts and shows what
+// ManifestProcessor would change on the cluster, without changing anything.
+// It's meant to let users preview an upgrade (e.g. before flipping an SMCP's
+// spec.version) by pointing the processor's dry-run mode at a real kubeconfig.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ghodss/yaml"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/engine"
+	"k8s.io/helm/pkg/manifest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/scheme"
+
+	"github.com/maistra/istio-operator/pkg/controller/common"
+)
+
+var log = logf.Log.WithName("istio-operator-diff")
+
+func main() {
+	chartsDir := flag.String("charts-dir", "", "Directory containing the chart to render and diff against the cluster")
+	valuesFile := flag.String("values", "", "Path to a Helm values.yaml file to render the chart with")
+	releaseName := flag.String("release-name", "istio", "Release name to render the chart as")
+	namespace := flag.String("namespace", "istio-system", "Namespace the control plane is (or would be) installed into")
+	owner := flag.String("owner", "istio-system", "Value of the maistra.io/owner label used to scope the diff")
+	component := flag.String("component", "istio", "Value of the app.kubernetes.io/component label used to scope the diff")
+	flag.Parse()
+
+	if *chartsDir == "" {
+		fmt.Fprintln(os.Stderr, "--charts-dir is required")
+		os.Exit(1)
+	}
+
+	report, err := run(*chartsDir, *valuesFile, *releaseName, *namespace, *owner, *component)
+	if err != nil {
+		log.Error(err, "error generating dry-run report")
+		os.Exit(1)
+	}
+
+	for _, entry := range report.Entries {
+		fmt.Printf("%s\t%s\n", entry.Action, entry.Resource)
+		if entry.Diff != "" {
+			fmt.Println(entry.Diff)
+		}
+	}
+	fmt.Println(report.Summarize())
+}
+
+func run(chartsDir, valuesFile, releaseName, namespace, owner, component string) (*common.ReconcileReport, error) {
+	values := map[string]interface{}{}
+	if valuesFile != "" {
+		raw, err := os.ReadFile(valuesFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading values file: %v", err)
+		}
+		if err := yaml.Unmarshal(raw, &values); err != nil {
+			return nil, fmt.Errorf("error parsing values file: %v", err)
+		}
+	}
+
+	chrt, err := chartutil.Load(chartsDir)
+	if err != nil {
+		return nil, fmt.Errorf("error loading chart from %s: %v", chartsDir, err)
+	}
+
+	renderValues, err := chartutil.ToRenderValuesCaps(chrt, &chartutil.Config{}, chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: namespace,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error computing render values: %v", err)
+	}
+	if err := chartutil.SetValues(renderValues, values); err != nil {
+		return nil, fmt.Errorf("error merging user-supplied values: %v", err)
+	}
+
+	rendered, err := engine.New().Render(chrt, renderValues)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering chart: %v", err)
+	}
+
+	manifests := make([]manifest.Manifest, 0, len(rendered))
+	for name, content := range rendered {
+		manifests = append(manifests, manifest.Manifest{Name: name, Content: content})
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading kubeconfig: %v", err)
+	}
+	cl, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		return nil, fmt.Errorf("error creating client: %v", err)
+	}
+
+	noop := func(ctx context.Context, obj *unstructured.Unstructured) error { return nil }
+	processor := common.NewManifestProcessor(common.ControllerResources{Client: cl, Log: log}, releaseName, chrt.GetMetadata().GetVersion(), owner, noop, noop)
+	processor.SetDryRun(true)
+
+	ctx := common.NewContextWithLog(context.Background(), log)
+	if err := processor.ProcessManifests(ctx, manifests, component); err != nil {
+		return nil, fmt.Errorf("error generating dry-run report: %v", err)
+	}
+	return processor.LastReport(), nil
+}
@@ -5,8 +5,11 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 
+	"github.com/fsnotify/fsnotify"
+
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	"github.com/magiconair/properties"
 	"github.com/mitchellh/mapstructure"
@@ -31,6 +34,7 @@ import (
 	maistrav1 "github.com/maistra/istio-operator/pkg/apis/maistra/v1"
 	"github.com/maistra/istio-operator/pkg/controller"
 	"github.com/maistra/istio-operator/pkg/controller/common"
+	"github.com/maistra/istio-operator/pkg/controller/servicemesh/memberroll"
 	"github.com/maistra/istio-operator/pkg/version"
 )
 
@@ -87,10 +91,12 @@ func main() {
 
 	log.Info(fmt.Sprintf("Starting Istio Operator %s", version.Info))
 
-	if err := initializeConfiguration(configFile); err != nil {
+	v, err := initializeConfiguration(configFile)
+	if err != nil {
 		log.Error(err, "error initializing operator configuration")
 		os.Exit(1)
 	}
+	watchConfigurationForChanges(v, configFile)
 
 	namespace, err := k8sutil.GetWatchNamespace()
 	if err != nil {
@@ -136,12 +142,23 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Index NetworkPolicies by the mesh namespace they belong to before the
+	// cache starts, so SweepOrphanNetworkPolicies can fetch each mesh's
+	// NetworkPolicies from it once the cache has synced.
+	if err := memberroll.RegisterNetworkPolicyMemberOfIndexer(mgr); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
 	// Setup all Controllers
 	if err := controller.AddToManager(mgr); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 
+	stopCh := signals.SetupSignalHandler()
+	go sweepOrphanNetworkPoliciesOnceSynced(mgr, stopCh)
+
 	if err = serveCRMetrics(cfg); err != nil {
 		log.Info("Could not generate and serve custom resource metrics", "error", err.Error())
 	}
@@ -173,12 +190,27 @@ func main() {
 	log.Info("Starting the Cmd.")
 
 	// Start the Cmd
-	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(stopCh); err != nil {
 		log.Error(err, "Manager exited non-zero")
 		os.Exit(1)
 	}
 }
 
+// sweepOrphanNetworkPoliciesOnceSynced waits for mgr's cache to finish its
+// initial sync, then runs SweepOrphanNetworkPolicies once, so a mesh deleted
+// while the operator was down doesn't leave its members' NetworkPolicy
+// copies behind indefinitely. It gives up without sweeping if stopCh closes
+// (e.g. the operator is shutting down) before the cache syncs.
+func sweepOrphanNetworkPoliciesOnceSynced(mgr manager.Manager, stopCh <-chan struct{}) {
+	if !mgr.GetCache().WaitForCacheSync(stopCh) {
+		log.Info("cache did not sync before shutdown; skipping orphaned NetworkPolicy sweep")
+		return
+	}
+	if err := memberroll.SweepOrphanNetworkPolicies(context.Background(), mgr.GetClient()); err != nil {
+		log.Error(err, "error sweeping orphaned NetworkPolicies")
+	}
+}
+
 // serveCRMetrics gets the Operator/CustomResource GVKs and generates metrics based on those types.
 // It serves those metrics on "http://metricsHost:operatorMetricsPort".
 func serveCRMetrics(cfg *rest.Config) error {
@@ -203,10 +235,10 @@ func serveCRMetrics(cfg *rest.Config) error {
 	return nil
 }
 
-func initializeConfiguration(configFile string) error {
+func initializeConfiguration(configFile string) (*viper.Viper, error) {
 	v, err := common.NewViper()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// map flags to config structure
@@ -225,6 +257,17 @@ func initializeConfiguration(configFile string) error {
 
 	v.BindPFlags(pflag.CommandLine)
 	v.AutomaticEnv()
+
+	if err := loadConfigurationFile(v, configFile); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// loadConfigurationFile merges configFile's properties into v and re-unmarshals
+// the result into common.Config. It's used both for the initial load and, by
+// watchConfigurationForChanges, every time the file changes on disk.
+func loadConfigurationFile(v *viper.Viper, configFile string) error {
 	props, err := patchProperties(configFile)
 	if err != nil {
 		return err
@@ -235,12 +278,61 @@ func initializeConfiguration(configFile string) error {
 		}
 	}
 
-	if err := v.Unmarshal(common.Config, func(dc *mapstructure.DecoderConfig) {
+	return v.Unmarshal(common.Config, func(dc *mapstructure.DecoderConfig) {
 		dc.TagName = "json"
-	}); err != nil {
-		return err
+	})
+}
+
+// watchConfigurationForChanges watches configFile for changes and, whenever it
+// is written, reloads it into v and re-unmarshals into common.Config. This lets
+// operators tune settings like apiBurst/apiQPS or reconciler concurrency via
+// their ConfigMap/Secret mount without restarting the operator pod. Failure to
+// start the watch is logged but not fatal: the operator still runs with the
+// configuration it loaded at startup.
+func watchConfigurationForChanges(v *viper.Viper, configFile string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Error(err, "could not start configuration file watcher; configuration hot-reload is disabled")
+		return
 	}
-	return nil
+	// watch the containing directory rather than the file itself, since
+	// ConfigMap/Secret volume mounts replace the file via a symlink swap,
+	// which most filesystem watchers don't see as an event on the file.
+	if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+		log.Error(err, "could not watch configuration file directory; configuration hot-reload is disabled", "file", configFile)
+		watcher.Close()
+		return
+	}
+
+	realConfigFile, _ := filepath.EvalSymlinks(configFile)
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// a direct write to the file, or a symlink swap that changed
+				// which file configFile resolves to
+				currentConfigFile, _ := filepath.EvalSymlinks(configFile)
+				written := filepath.Clean(event.Name) == filepath.Clean(configFile) && event.Op&(fsnotify.Write|fsnotify.Create) != 0
+				swapped := currentConfigFile != "" && currentConfigFile != realConfigFile
+				if !written && !swapped {
+					continue
+				}
+				realConfigFile = currentConfigFile
+				log.Info("operator configuration file changed; reloading", "file", configFile)
+				if err := loadConfigurationFile(v, configFile); err != nil {
+					log.Error(err, "error reloading operator configuration")
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error(err, "error watching operator configuration file")
+			}
+		}
+	}()
 }
 
 // downward api quotes values in the file (fmt.Sprintf("%q")), so we need to Unquote() them
@@ -0,0 +1,36 @@
+package conversion
+
+// stripReplicaCountIfAutoscaled removes componentValues["replicaCount"] when
+// componentValues["autoscaleEnabled"] is true. replicaCount and an HPA
+// targeting the same Deployment fight over the replica count, so once a
+// component is autoscaled, replicaCount must not be rendered into its Helm
+// values - the field stays on the v2 spec (so the setting isn't lost across
+// a round trip) but is withheld from what's actually applied to the cluster.
+func stripReplicaCountIfAutoscaled(componentValues map[string]interface{}) {
+	if enabled, ok := componentValues["autoscaleEnabled"].(bool); ok && enabled {
+		delete(componentValues, "replicaCount")
+	}
+}
+
+// FinalizeComponentAutoscaleValues applies both of this package's
+// autoscaleEnabled-driven fixups to a single component's rendered Helm
+// values: it strips replicaCount via stripReplicaCountIfAutoscaled so it
+// doesn't fight with an HPA, and, when managedByHPA indicates the component
+// also ships its own HorizontalPodAutoscaler object, records
+// checkDeprecatedAutoscaleEnabled's warning onto warnings. Per-component
+// value population (e.g. populateGrafanaValues) should call this once it's
+// done setting componentValues, rather than calling the two checks
+// separately and risking one being forgotten.
+//
+// Not done here: calling this from real per-component value population.
+// Every component populate function in this checkout (e.g. populateProxyValues)
+// renders fields unrelated to autoscaleEnabled/replicaCount, and the ones that
+// would actually need it don't exist here - they depend on the
+// populateAddonsValues entrypoint and pkg/apis/maistra/v2 types, neither of
+// which is present in this checkout.
+func FinalizeComponentAutoscaleValues(component string, componentValues map[string]interface{}, managedByHPA bool, warnings *conversionWarnings) {
+	if warning, ok := checkDeprecatedAutoscaleEnabled(component, componentValues, managedByHPA); ok {
+		warnings.Addf("%s", warning)
+	}
+	stripReplicaCountIfAutoscaled(componentValues)
+}
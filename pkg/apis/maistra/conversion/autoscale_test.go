@@ -0,0 +1,67 @@
+package conversion
+
+import "testing"
+
+func TestStripReplicaCountIfAutoscaled(t *testing.T) {
+	values := map[string]interface{}{
+		"autoscaleEnabled": true,
+		"replicaCount":     2,
+	}
+	stripReplicaCountIfAutoscaled(values)
+	if _, ok := values["replicaCount"]; ok {
+		t.Error("expected replicaCount to be stripped when autoscaleEnabled is true")
+	}
+}
+
+func TestStripReplicaCountIfAutoscaledLeavesReplicaCountWhenDisabled(t *testing.T) {
+	values := map[string]interface{}{
+		"autoscaleEnabled": false,
+		"replicaCount":     2,
+	}
+	stripReplicaCountIfAutoscaled(values)
+	if values["replicaCount"] != 2 {
+		t.Errorf("expected replicaCount to be left alone, got %v", values["replicaCount"])
+	}
+}
+
+func TestStripReplicaCountIfAutoscaledLeavesReplicaCountWhenUnset(t *testing.T) {
+	values := map[string]interface{}{
+		"replicaCount": 2,
+	}
+	stripReplicaCountIfAutoscaled(values)
+	if values["replicaCount"] != 2 {
+		t.Errorf("expected replicaCount to be left alone when autoscaleEnabled is absent, got %v", values["replicaCount"])
+	}
+}
+
+func TestFinalizeComponentAutoscaleValuesStripsAndWarns(t *testing.T) {
+	values := map[string]interface{}{
+		"autoscaleEnabled": true,
+		"replicaCount":     2,
+	}
+	var warnings conversionWarnings
+	FinalizeComponentAutoscaleValues("grafana", values, true, &warnings)
+
+	if _, ok := values["replicaCount"]; ok {
+		t.Error("expected replicaCount to be stripped")
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected a deprecation warning when the component is managed by an HPA, got %v", warnings)
+	}
+}
+
+func TestFinalizeComponentAutoscaleValuesStripsWithoutWarningWhenNotHPAManaged(t *testing.T) {
+	values := map[string]interface{}{
+		"autoscaleEnabled": true,
+		"replicaCount":     2,
+	}
+	var warnings conversionWarnings
+	FinalizeComponentAutoscaleValues("grafana", values, false, &warnings)
+
+	if _, ok := values["replicaCount"]; ok {
+		t.Error("expected replicaCount to still be stripped")
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warning when the component isn't HPA-managed, got %v", warnings)
+	}
+}
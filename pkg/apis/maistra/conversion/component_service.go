@@ -0,0 +1,77 @@
+package conversion
+
+import "fmt"
+
+// componentIngressConfig models the subset of v2.ComponentServiceConfig's
+// ingress settings needed to derive an externally reachable URL for a
+// component fronted by an Ingress.
+type componentIngressConfig struct {
+	Enabled bool
+	Hosts   []string
+	// TLSEnabled indicates TLS termination is configured on the Ingress, so
+	// the derived URL should use the https scheme.
+	TLSEnabled bool
+}
+
+// componentExternalAddressConfig models the subset of v2.ComponentServiceConfig
+// needed to derive the URL at which a component (e.g. Grafana) is reachable
+// from outside the cluster, for auto-populating fields like Kiali's
+// dashboard.grafanaURL. It mirrors the shape that field is expected to take
+// on v2.ComponentServiceConfig; once that type exists it should replace this
+// local definition rather than duplicate it.
+type componentExternalAddressConfig struct {
+	// Address is a user-supplied override. If set, it always wins over any
+	// value derived from NodePort or Ingress.
+	Address string
+	// NodePort is the externally reachable port when the component's Service
+	// is of type NodePort. externalAddress's nodePortHost argument is required
+	// alongside it, since there's no way to discover a node's external IP/DNS
+	// name from the spec alone.
+	NodePort *int32
+	Ingress  *componentIngressConfig
+}
+
+// externalAddress derives the externally reachable URL for a component given
+// its service configuration, returning ("", false) if none can be derived.
+// nodePortHost is the cluster-external host/IP to use for a NodePort-derived URL;
+// for an Ingress-derived URL, Ingress.Hosts[0] is used instead.
+func externalAddress(cfg componentExternalAddressConfig, nodePortHost string) (string, bool) {
+	if cfg.Address != "" {
+		return cfg.Address, true
+	}
+	if cfg.NodePort != nil && nodePortHost != "" {
+		return fmt.Sprintf("http://%s:%d", nodePortHost, *cfg.NodePort), true
+	}
+	if cfg.Ingress != nil && cfg.Ingress.Enabled && len(cfg.Ingress.Hosts) > 0 {
+		scheme := "http"
+		if cfg.Ingress.TLSEnabled {
+			scheme = "https"
+		}
+		return fmt.Sprintf("%s://%s", scheme, cfg.Ingress.Hosts[0]), true
+	}
+	return "", false
+}
+
+// setKialiDashboardURL sets one of Kiali's kiali.dashboard.{grafanaURL,
+// jaegerURL,prometheusURL} Helm values, leaving values untouched if url is empty.
+func setKialiDashboardURL(values map[string]interface{}, field, url string) error {
+	if url == "" {
+		return nil
+	}
+	return setHelmValue(values, "kiali.dashboard."+field, url)
+}
+
+// PopulateKialiDashboardURL derives cfg's externally reachable URL (via
+// externalAddress) and, if one can be derived, sets it on Kiali's
+// kiali.dashboard.<field> Helm value (via setKialiDashboardURL). field is one
+// of "grafanaURL", "jaegerURL" or "prometheusURL". This is the single call
+// the Grafana/Jaeger/Prometheus addon conversion should make once it's
+// finished populating cfg from the component's own service config, instead
+// of calling externalAddress and setKialiDashboardURL separately.
+func PopulateKialiDashboardURL(values map[string]interface{}, field string, cfg componentExternalAddressConfig, nodePortHost string) error {
+	url, ok := externalAddress(cfg, nodePortHost)
+	if !ok {
+		return nil
+	}
+	return setKialiDashboardURL(values, field, url)
+}
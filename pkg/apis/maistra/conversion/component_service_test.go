@@ -0,0 +1,89 @@
+package conversion
+
+import "testing"
+
+func TestExternalAddressPrefersExplicitAddress(t *testing.T) {
+	port := int32(30080)
+	cfg := componentExternalAddressConfig{
+		Address: "http://grafana.example.org",
+		NodePort: &port,
+		Ingress: &componentIngressConfig{Enabled: true, Hosts: []string{"grafana.ingress.example.org"}},
+	}
+	address, ok := externalAddress(cfg, "node.example.org")
+	if !ok || address != "http://grafana.example.org" {
+		t.Errorf("expected explicit address to win, got %q, %t", address, ok)
+	}
+}
+
+func TestExternalAddressFromNodePort(t *testing.T) {
+	port := int32(30080)
+	cfg := componentExternalAddressConfig{NodePort: &port}
+	address, ok := externalAddress(cfg, "node.example.org")
+	if !ok || address != "http://node.example.org:30080" {
+		t.Errorf("unexpected nodeport-derived address: %q, %t", address, ok)
+	}
+}
+
+func TestExternalAddressFromIngress(t *testing.T) {
+	cfg := componentExternalAddressConfig{
+		Ingress: &componentIngressConfig{Enabled: true, Hosts: []string{"grafana.ingress.example.org"}, TLSEnabled: true},
+	}
+	address, ok := externalAddress(cfg, "")
+	if !ok || address != "https://grafana.ingress.example.org" {
+		t.Errorf("unexpected ingress-derived address: %q, %t", address, ok)
+	}
+}
+
+func TestExternalAddressNone(t *testing.T) {
+	if _, ok := externalAddress(componentExternalAddressConfig{}, "node.example.org"); ok {
+		t.Error("expected no derivable address")
+	}
+}
+
+func TestSetKialiDashboardURL(t *testing.T) {
+	values := map[string]interface{}{}
+	if err := setKialiDashboardURL(values, "grafanaURL", "http://grafana.example.org"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	kiali := values["kiali"].(map[string]interface{})
+	dashboard := kiali["dashboard"].(map[string]interface{})
+	if dashboard["grafanaURL"] != "http://grafana.example.org" {
+		t.Errorf("expected kiali.dashboard.grafanaURL to be set, got %v", dashboard["grafanaURL"])
+	}
+}
+
+func TestSetKialiDashboardURLSkipsEmpty(t *testing.T) {
+	values := map[string]interface{}{}
+	if err := setKialiDashboardURL(values, "grafanaURL", ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := values["kiali"]; ok {
+		t.Error("expected no kiali values to be set for an empty URL")
+	}
+}
+
+func TestPopulateKialiDashboardURLFromNodePort(t *testing.T) {
+	port := int32(30080)
+	values := map[string]interface{}{}
+	cfg := componentExternalAddressConfig{NodePort: &port}
+
+	if err := PopulateKialiDashboardURL(values, "grafanaURL", cfg, "node.example.org"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	kiali := values["kiali"].(map[string]interface{})
+	dashboard := kiali["dashboard"].(map[string]interface{})
+	if dashboard["grafanaURL"] != "http://node.example.org:30080" {
+		t.Errorf("expected kiali.dashboard.grafanaURL to be derived from the NodePort, got %v", dashboard["grafanaURL"])
+	}
+}
+
+func TestPopulateKialiDashboardURLSkipsWhenUnderivable(t *testing.T) {
+	values := map[string]interface{}{}
+	if err := PopulateKialiDashboardURL(values, "grafanaURL", componentExternalAddressConfig{}, ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := values["kiali"]; ok {
+		t.Error("expected no kiali values to be set when no address can be derived")
+	}
+}
@@ -0,0 +1,77 @@
+package conversion
+
+import "fmt"
+
+// conversionWarnings accumulates non-fatal messages raised while converting
+// between the v1/v2 API and Helm values, e.g. use of a deprecated field that
+// conversion can still honor. Unlike the errors returned alongside it,
+// warnings never prevent conversion from succeeding.
+type conversionWarnings []string
+
+// Addf appends a formatted warning.
+func (w *conversionWarnings) Addf(format string, args ...interface{}) {
+	*w = append(*w, fmt.Sprintf(format, args...))
+}
+
+// deprecatedHelmKeyWarning formats the standard message used when a
+// component's Helm values use a Helm key that's deprecated in favor of a
+// replacement, e.g. the old rollingMaxSurge/rollingMaxUnavailable keys now
+// superseded by a typed strategy block.
+func deprecatedHelmKeyWarning(component, deprecatedKey, replacement string) string {
+	return fmt.Sprintf("%s: %q is deprecated; use %q instead", component, deprecatedKey, replacement)
+}
+
+// checkDeprecatedAutoscaleEnabled returns a warning when a component sets
+// autoscaleEnabled even though it's managed by an HPA object directly, since
+// in that mode the field has no effect and its presence usually indicates
+// stale configuration left over from before the HPA was introduced.
+func checkDeprecatedAutoscaleEnabled(component string, componentValues map[string]interface{}, managedByHPA bool) (string, bool) {
+	if !managedByHPA {
+		return "", false
+	}
+	if enabled, ok := componentValues["autoscaleEnabled"].(bool); ok && enabled {
+		return fmt.Sprintf("%s: \"autoscaleEnabled\" has no effect; this component's replica count is managed by its HorizontalPodAutoscaler", component), true
+	}
+	return "", false
+}
+
+// checkDeprecatedRollingUpdateKeys returns a warning for each of
+// rollingMaxSurge/rollingMaxUnavailable present in componentValues, since
+// newer charts expose the same setting through a typed strategy block.
+func checkDeprecatedRollingUpdateKeys(component string, componentValues map[string]interface{}) []string {
+	var warnings []string
+	for _, key := range []string{"rollingMaxSurge", "rollingMaxUnavailable"} {
+		if _, ok := componentValues[key]; ok {
+			warnings = append(warnings, deprecatedHelmKeyWarning(component, key, "strategy"))
+		}
+	}
+	return warnings
+}
+
+// CollectComponentDeprecationWarnings runs this package's Helm-key
+// deprecation checks (checkDeprecatedRollingUpdateKeys) against a single
+// component's rendered Helm values and appends any resulting messages to
+// warnings. Conversion should call this once per component - alongside
+// FinalizeComponentAutoscaleValues, which covers the autoscaleEnabled
+// deprecation check - rather than calling checkDeprecatedRollingUpdateKeys
+// directly.
+//
+// The caller owns turning an accumulated, non-empty conversionWarnings into
+// user-visible feedback. In the real operator that almost certainly means
+// setting a "Deprecated" status Condition on the owning
+// ServiceMeshControlPlane; that Condition type isn't defined anywhere in
+// this checkout (pkg/apis/maistra/v1 and v2 aren't present here), so this
+// function stops at producing the warning strings rather than guessing at
+// the status API shape.
+//
+// Not done here: calling this from a real per-component value population
+// entrypoint - it's currently only exercised by deprecation_test.go. The
+// per-component populate functions that exist in this checkout don't render
+// rollingMaxSurge/rollingMaxUnavailable, and the ones that would depend on
+// the populateAddonsValues entrypoint and pkg/apis/maistra/v2 types above,
+// neither of which is present here.
+func CollectComponentDeprecationWarnings(component string, componentValues map[string]interface{}, warnings *conversionWarnings) {
+	for _, warning := range checkDeprecatedRollingUpdateKeys(component, componentValues) {
+		warnings.Addf("%s", warning)
+	}
+}
@@ -0,0 +1,59 @@
+package conversion
+
+import "testing"
+
+func TestConversionWarningsAddf(t *testing.T) {
+	var warnings conversionWarnings
+	warnings.Addf("%s: %s is deprecated", "grafana", "rollingMaxSurge")
+	if len(warnings) != 1 || warnings[0] != "grafana: rollingMaxSurge is deprecated" {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+}
+
+func TestCheckDeprecatedAutoscaleEnabled(t *testing.T) {
+	values := map[string]interface{}{"autoscaleEnabled": true}
+	if _, ok := checkDeprecatedAutoscaleEnabled("grafana", values, false); ok {
+		t.Error("expected no warning when the component isn't managed by an HPA")
+	}
+	warning, ok := checkDeprecatedAutoscaleEnabled("grafana", values, true)
+	if !ok || warning == "" {
+		t.Error("expected a warning when autoscaleEnabled is set on an HPA-managed component")
+	}
+}
+
+func TestCheckDeprecatedRollingUpdateKeys(t *testing.T) {
+	values := map[string]interface{}{
+		"rollingMaxSurge":       "25%",
+		"rollingMaxUnavailable": "25%",
+	}
+	warnings := checkDeprecatedRollingUpdateKeys("grafana", values)
+	if len(warnings) != 2 {
+		t.Errorf("expected 2 warnings, got %d: %v", len(warnings), warnings)
+	}
+}
+
+func TestCheckDeprecatedRollingUpdateKeysNoneSet(t *testing.T) {
+	if warnings := checkDeprecatedRollingUpdateKeys("grafana", map[string]interface{}{}); len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+}
+
+func TestCollectComponentDeprecationWarnings(t *testing.T) {
+	values := map[string]interface{}{
+		"rollingMaxSurge":       "25%",
+		"rollingMaxUnavailable": "25%",
+	}
+	var warnings conversionWarnings
+	CollectComponentDeprecationWarnings("grafana", values, &warnings)
+	if len(warnings) != 2 {
+		t.Errorf("expected 2 warnings, got %v", warnings)
+	}
+}
+
+func TestCollectComponentDeprecationWarningsNoneSet(t *testing.T) {
+	var warnings conversionWarnings
+	CollectComponentDeprecationWarnings("grafana", map[string]interface{}{}, &warnings)
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+}
@@ -0,0 +1,150 @@
+package conversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// convertValueForField coerces raw - a value decoded from an untyped Helm
+// values map - to the type of the field addressed by path (a dotted sequence
+// of JSON tag names, e.g. "grafana.runtime.autoscaling.maxReplicas") within
+// structType. It exists because values round-tripped through YAML/JSON can
+// arrive as the wrong concrete type for the destination field: numbers as
+// strings or json.Number, or vice versa.
+//
+// intstr.IntOrString fields are left untouched - raw is returned as-is -
+// since IntOrString already accepts both representations and has its own
+// marshaling rules that this helper shouldn't second-guess.
+func convertValueForField(structType reflect.Type, path string, raw interface{}) (interface{}, error) {
+	fieldType, err := resolveFieldType(structType, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fieldType == reflect.TypeOf(intstr.IntOrString{}) {
+		return raw, nil
+	}
+
+	if raw == nil {
+		return raw, nil
+	}
+
+	rawValue := reflect.ValueOf(raw)
+	if rawValue.Type().AssignableTo(fieldType) {
+		return raw, nil
+	}
+
+	switch fieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := coerceToInt64(raw)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %s", path, err)
+		}
+		return reflect.ValueOf(i).Convert(fieldType).Interface(), nil
+	case reflect.String:
+		switch v := raw.(type) {
+		case string:
+			return v, nil
+		case fmt.Stringer:
+			return v.String(), nil
+		default:
+			return fmt.Sprintf("%v", raw), nil
+		}
+	case reflect.Bool:
+		if b, ok := raw.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("field %q: cannot convert %T to bool", path, raw)
+	default:
+		return nil, fmt.Errorf("field %q: cannot convert %T to %s", path, raw, fieldType)
+	}
+}
+
+// CoerceHelmFieldValue looks up path (a dotted Helm value path, e.g.
+// "grafana.runtime.autoscaling.maxReplicas") within values, coerces it via
+// convertValueForField against structType - the v2 API type the field is
+// destined for once conversion reads it back out of Helm values - and writes
+// the coerced value back to the same path. It's a no-op if nothing is set at
+// path. Conversion code reading typed fields back out of a rendered Helm
+// values tree should call this for each field it's about to type-assert,
+// rather than calling convertValueForField and setHelmValue separately.
+//
+// Not done here: calling this from the real Helm-values-to-v2 conversion
+// path. That direction of conversion (reading a rendered Helm values tree
+// back into typed v2 fields) isn't implemented anywhere in this checkout -
+// it depends on the pkg/apis/maistra/v2 types, which aren't present here.
+func CoerceHelmFieldValue(structType reflect.Type, values map[string]interface{}, path string) error {
+	raw, ok, err := getHelmValue(values, path)
+	if err != nil || !ok {
+		return err
+	}
+	coerced, err := convertValueForField(structType, path, raw)
+	if err != nil {
+		return err
+	}
+	return setHelmValue(values, path, coerced)
+}
+
+func coerceToInt64(raw interface{}) (int64, error) {
+	switch v := raw.(type) {
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case json.Number:
+		return v.Int64()
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float32:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("cannot convert %T to int", raw)
+	}
+}
+
+// resolveFieldType walks structType field-by-field following path, a dotted
+// sequence of JSON tag names, and returns the reflect.Type of the field at
+// the end of the path. Pointer and slice element types are dereferenced
+// automatically when a path segment continues past them.
+func resolveFieldType(structType reflect.Type, path string) (reflect.Type, error) {
+	current := structType
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		for current.Kind() == reflect.Ptr || current.Kind() == reflect.Slice {
+			current = current.Elem()
+		}
+		if current.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("field %q: %s is not a struct", path, strings.Join(segments[:i], "."))
+		}
+		field, ok := fieldByJSONTag(current, segment)
+		if !ok {
+			return nil, fmt.Errorf("field %q: no field matching %q on %s", path, segment, current)
+		}
+		current = field.Type
+	}
+	for current.Kind() == reflect.Ptr {
+		current = current.Elem()
+	}
+	return current, nil
+}
+
+func fieldByJSONTag(structType reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag := field.Tag.Get("json")
+		tagName := strings.Split(tag, ",")[0]
+		if tagName == name || (tagName == "" && field.Name == name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,112 @@
+package conversion
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// These fixtures stand in for the real v2.ControlPlaneSpec component types
+// (e.g. a Grafana/Prometheus runtime/autoscaling block) that
+// convertValueForField/CoerceHelmFieldValue are meant to coerce fields
+// against; the v2 API package doesn't exist in this checkout, so they're
+// shaped to match its expected field names and nesting instead of importing
+// it directly.
+type fieldCoercionRuntimeFixture struct {
+	MaxReplicas int                `json:"maxReplicas"`
+	Strategy    intstr.IntOrString `json:"strategy"`
+}
+
+type fieldCoercionComponentFixture struct {
+	Name       string                       `json:"name"`
+	Enabled    bool                         `json:"enabled"`
+	Runtime    fieldCoercionRuntimeFixture  `json:"runtime"`
+	RuntimePtr *fieldCoercionRuntimeFixture `json:"runtimePtr"`
+}
+
+func TestConvertValueForFieldStringToInt(t *testing.T) {
+	got, err := convertValueForField(reflect.TypeOf(fieldCoercionComponentFixture{}), "runtime.maxReplicas", "3")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != 3 {
+		t.Errorf("expected 3, got %v (%T)", got, got)
+	}
+}
+
+func TestConvertValueForFieldJSONNumberToInt(t *testing.T) {
+	got, err := convertValueForField(reflect.TypeOf(fieldCoercionComponentFixture{}), "runtime.maxReplicas", json.Number("5"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != 5 {
+		t.Errorf("expected 5, got %v (%T)", got, got)
+	}
+}
+
+func TestConvertValueForFieldIntToString(t *testing.T) {
+	got, err := convertValueForField(reflect.TypeOf(fieldCoercionComponentFixture{}), "name", 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "7" {
+		t.Errorf("expected \"7\", got %v (%T)", got, got)
+	}
+}
+
+func TestConvertValueForFieldLeavesIntOrStringAlone(t *testing.T) {
+	got, err := convertValueForField(reflect.TypeOf(fieldCoercionComponentFixture{}), "runtime.strategy", "25%")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "25%" {
+		t.Errorf("expected the raw value to be returned untouched, got %v (%T)", got, got)
+	}
+}
+
+func TestConvertValueForFieldThroughPointer(t *testing.T) {
+	got, err := convertValueForField(reflect.TypeOf(fieldCoercionComponentFixture{}), "runtimePtr.maxReplicas", "4")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != 4 {
+		t.Errorf("expected 4, got %v (%T)", got, got)
+	}
+}
+
+func TestConvertValueForFieldUnknownPath(t *testing.T) {
+	if _, err := convertValueForField(reflect.TypeOf(fieldCoercionComponentFixture{}), "bogus", "x"); err == nil {
+		t.Error("expected an error for an unknown field path")
+	}
+}
+
+func TestConvertValueForFieldIncompatibleType(t *testing.T) {
+	if _, err := convertValueForField(reflect.TypeOf(fieldCoercionComponentFixture{}), "enabled", "not-a-bool"); err == nil {
+		t.Error("expected an error coercing a non-bool string to bool")
+	}
+}
+
+func TestCoerceHelmFieldValueRewritesValuesInPlace(t *testing.T) {
+	values := map[string]interface{}{
+		"runtime": map[string]interface{}{"maxReplicas": "3"},
+	}
+	if err := CoerceHelmFieldValue(reflect.TypeOf(fieldCoercionComponentFixture{}), values, "runtime.maxReplicas"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	runtime := values["runtime"].(map[string]interface{})
+	if runtime["maxReplicas"] != 3 {
+		t.Errorf("expected runtime.maxReplicas to be rewritten to the int 3, got %v (%T)", runtime["maxReplicas"], runtime["maxReplicas"])
+	}
+}
+
+func TestCoerceHelmFieldValueSkipsUnsetPath(t *testing.T) {
+	values := map[string]interface{}{}
+	if err := CoerceHelmFieldValue(reflect.TypeOf(fieldCoercionComponentFixture{}), values, "runtime.maxReplicas"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := values["runtime"]; ok {
+		t.Error("expected no values to be set for an absent path")
+	}
+}
@@ -0,0 +1,160 @@
+package conversion
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// grafanaLDAPConfig models the fields needed to render Grafana's ldap.toml.
+// It mirrors the shape v2.GrafanaSecurityConfig.LDAP is expected to take;
+// once that type exists it should replace this local definition rather than
+// duplicate it.
+type grafanaLDAPConfig struct {
+	BindDN                string
+	BindPasswordSecretRef *corev1.SecretKeySelector
+	Servers               []grafanaLDAPServer
+	SearchFilter          string
+	SearchBaseDNs         []string
+	Attributes            grafanaLDAPAttributes
+	GroupMappings         []grafanaLDAPGroupMapping
+}
+
+type grafanaLDAPServer struct {
+	Host                string
+	Port                int32
+	UseSSL              bool
+	StartTLS            bool
+	SSLSkipVerify       bool
+	RootCACertSecretRef *corev1.SecretKeySelector
+}
+
+type grafanaLDAPAttributes struct {
+	Username string
+	Name     string
+	Surname  string
+	Email    string
+	MemberOf string
+}
+
+type grafanaLDAPGroupMapping struct {
+	GroupDN      string
+	OrgRole      string
+	GrafanaAdmin bool
+}
+
+const (
+	grafanaLDAPConfigFile        = "/etc/grafana/ldap.toml"
+	grafanaLDAPSecretLDAPTOMLKey = "ldap.toml"
+)
+
+// renderGrafanaLDAPConfig renders cfg as the contents of Grafana's ldap.toml.
+// The bind password itself is never rendered into the file; when
+// BindPasswordSecretRef is set, bind_password is rendered as a $__file token
+// pointing at the path the secret key is expected to be mounted at - callers
+// are responsible for mounting BindPasswordSecretRef there.
+func renderGrafanaLDAPConfig(cfg *grafanaLDAPConfig) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "[[servers]]\n")
+	for _, server := range cfg.Servers {
+		fmt.Fprintf(&b, "host = %q\n", server.Host)
+		fmt.Fprintf(&b, "port = %d\n", server.Port)
+		fmt.Fprintf(&b, "use_ssl = %t\n", server.UseSSL)
+		fmt.Fprintf(&b, "start_tls = %t\n", server.StartTLS)
+		fmt.Fprintf(&b, "ssl_skip_verify = %t\n", server.SSLSkipVerify)
+		if server.RootCACertSecretRef != nil {
+			fmt.Fprintf(&b, "root_ca_cert = %q\n", grafanaLDAPRootCACertMountPath(server.RootCACertSecretRef))
+		}
+	}
+	fmt.Fprintf(&b, "bind_dn = %q\n", cfg.BindDN)
+	if cfg.BindPasswordSecretRef != nil {
+		fmt.Fprintf(&b, "bind_password = \"$__file{%s}\"\n", grafanaLDAPBindPasswordMountPath(cfg.BindPasswordSecretRef))
+	}
+	fmt.Fprintf(&b, "search_filter = %q\n", cfg.SearchFilter)
+	fmt.Fprintf(&b, "search_base_dns = [%s]\n", quoteJoin(cfg.SearchBaseDNs))
+
+	fmt.Fprintf(&b, "\n[servers.attributes]\n")
+	fmt.Fprintf(&b, "username = %q\n", cfg.Attributes.Username)
+	fmt.Fprintf(&b, "name = %q\n", cfg.Attributes.Name)
+	fmt.Fprintf(&b, "surname = %q\n", cfg.Attributes.Surname)
+	fmt.Fprintf(&b, "email = %q\n", cfg.Attributes.Email)
+	fmt.Fprintf(&b, "member_of = %q\n", cfg.Attributes.MemberOf)
+
+	for _, mapping := range cfg.GroupMappings {
+		fmt.Fprintf(&b, "\n[[servers.group_mappings]]\n")
+		fmt.Fprintf(&b, "group_dn = %q\n", mapping.GroupDN)
+		if mapping.OrgRole != "" {
+			fmt.Fprintf(&b, "org_role = %q\n", mapping.OrgRole)
+		}
+		fmt.Fprintf(&b, "grafana_admin = %t\n", mapping.GrafanaAdmin)
+	}
+
+	return b.String()
+}
+
+func quoteJoin(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func grafanaLDAPBindPasswordMountPath(ref *corev1.SecretKeySelector) string {
+	return fmt.Sprintf("/etc/grafana/secrets/%s", ref.Key)
+}
+
+func grafanaLDAPRootCACertMountPath(ref *corev1.SecretKeySelector) string {
+	return fmt.Sprintf("/etc/grafana/secrets/%s", ref.Key)
+}
+
+// populateGrafanaLDAPValues sets the grafana.security.ldap.* Helm values that
+// switch Grafana's deployment into LDAP mode: GF_AUTH_LDAP_ENABLED=true, the
+// ldap.toml config file path, and the name of the Secret (secretName, whose
+// grafanaLDAPSecretLDAPTOMLKey key holds the file) it should be mounted from.
+// The caller is
+// responsible for actually creating that Secret from renderGrafanaLDAPConfig's
+// output.
+func populateGrafanaLDAPValues(cfg *grafanaLDAPConfig, secretName string, values map[string]interface{}) error {
+	if err := setHelmValue(values, "grafana.security.ldap.enabled", true); err != nil {
+		return err
+	}
+	if err := setHelmValue(values, "grafana.security.ldap.secretName", secretName); err != nil {
+		return err
+	}
+	if err := setHelmValue(values, "grafana.env.GF_AUTH_LDAP_ENABLED", "true"); err != nil {
+		return err
+	}
+	return setHelmValue(values, "grafana.env.GF_AUTH_LDAP_CONFIG_FILE", grafanaLDAPConfigFile)
+}
+
+// BuildGrafanaLDAPSecret renders cfg's ldap.toml and returns the Secret
+// populateGrafanaLDAPValues's secretName refers to, ready to be created (or
+// updated) alongside Grafana's other rendered addon resources.
+func BuildGrafanaLDAPSecret(namespace, secretName string, cfg *grafanaLDAPConfig) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			grafanaLDAPSecretLDAPTOMLKey: renderGrafanaLDAPConfig(cfg),
+		},
+	}
+}
+
+// ApplyGrafanaLDAPConfig is the single entry point addon wiring should call
+// once an LDAP config is present: it sets the Helm values that switch
+// Grafana into LDAP mode and returns the Secret that must be created
+// alongside them, so the caller never ends up with secretName referring to a
+// Secret that doesn't exist.
+func ApplyGrafanaLDAPConfig(namespace, secretName string, cfg *grafanaLDAPConfig, values map[string]interface{}) (*corev1.Secret, error) {
+	if err := populateGrafanaLDAPValues(cfg, secretName, values); err != nil {
+		return nil, err
+	}
+	return BuildGrafanaLDAPSecret(namespace, secretName, cfg), nil
+}
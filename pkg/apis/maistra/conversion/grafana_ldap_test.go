@@ -0,0 +1,104 @@
+package conversion
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRenderGrafanaLDAPConfig(t *testing.T) {
+	cfg := &grafanaLDAPConfig{
+		BindDN: "cn=admin,dc=example,dc=org",
+		BindPasswordSecretRef: &corev1.SecretKeySelector{
+			Key: "bind-password",
+		},
+		Servers: []grafanaLDAPServer{
+			{
+				Host:     "ldap.example.org",
+				Port:     389,
+				StartTLS: true,
+			},
+		},
+		SearchFilter:  "(cn=%s)",
+		SearchBaseDNs: []string{"dc=example,dc=org"},
+		Attributes: grafanaLDAPAttributes{
+			Username: "cn",
+			Email:    "mail",
+		},
+		GroupMappings: []grafanaLDAPGroupMapping{
+			{GroupDN: "cn=admins,dc=example,dc=org", OrgRole: "Admin", GrafanaAdmin: true},
+		},
+	}
+
+	rendered := renderGrafanaLDAPConfig(cfg)
+
+	for _, want := range []string{
+		`host = "ldap.example.org"`,
+		`port = 389`,
+		`start_tls = true`,
+		`bind_dn = "cn=admin,dc=example,dc=org"`,
+		`bind_password = "$__file{/etc/grafana/secrets/bind-password}"`,
+		`search_filter = "(cn=%s)"`,
+		`username = "cn"`,
+		`group_dn = "cn=admins,dc=example,dc=org"`,
+		`org_role = "Admin"`,
+		`grafana_admin = true`,
+	} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("rendered ldap.toml missing %q, got:\n%s", want, rendered)
+		}
+	}
+}
+
+func TestPopulateGrafanaLDAPValues(t *testing.T) {
+	values := map[string]interface{}{}
+	cfg := &grafanaLDAPConfig{BindDN: "cn=admin,dc=example,dc=org"}
+
+	if err := populateGrafanaLDAPValues(cfg, "grafana-ldap-secret", values); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	grafana := values["grafana"].(map[string]interface{})
+	security := grafana["security"].(map[string]interface{})
+	ldap := security["ldap"].(map[string]interface{})
+	if ldap["enabled"] != true {
+		t.Errorf("expected grafana.security.ldap.enabled to be true, got %v", ldap["enabled"])
+	}
+	if ldap["secretName"] != "grafana-ldap-secret" {
+		t.Errorf("expected grafana.security.ldap.secretName to be grafana-ldap-secret, got %v", ldap["secretName"])
+	}
+
+	env := grafana["env"].(map[string]interface{})
+	if env["GF_AUTH_LDAP_ENABLED"] != "true" {
+		t.Errorf("expected grafana.env.GF_AUTH_LDAP_ENABLED to be true, got %v", env["GF_AUTH_LDAP_ENABLED"])
+	}
+	if env["GF_AUTH_LDAP_CONFIG_FILE"] != grafanaLDAPConfigFile {
+		t.Errorf("expected grafana.env.GF_AUTH_LDAP_CONFIG_FILE to be %s, got %v", grafanaLDAPConfigFile, env["GF_AUTH_LDAP_CONFIG_FILE"])
+	}
+}
+
+func TestApplyGrafanaLDAPConfigCreatesSecret(t *testing.T) {
+	values := map[string]interface{}{}
+	cfg := &grafanaLDAPConfig{BindDN: "cn=admin,dc=example,dc=org"}
+
+	secret, err := ApplyGrafanaLDAPConfig("istio-system", "grafana-ldap-secret", cfg, values)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if secret.GetName() != "grafana-ldap-secret" || secret.GetNamespace() != "istio-system" {
+		t.Errorf("expected Secret istio-system/grafana-ldap-secret, got %s/%s", secret.GetNamespace(), secret.GetName())
+	}
+	toml, ok := secret.StringData[grafanaLDAPSecretLDAPTOMLKey]
+	if !ok || !strings.Contains(toml, `bind_dn = "cn=admin,dc=example,dc=org"`) {
+		t.Errorf("expected Secret to contain the rendered ldap.toml, got %q", toml)
+	}
+
+	grafana := values["grafana"].(map[string]interface{})
+	security := grafana["security"].(map[string]interface{})
+	ldap := security["ldap"].(map[string]interface{})
+	if ldap["secretName"] != secret.GetName() {
+		t.Errorf("expected grafana.security.ldap.secretName to match the Secret's name, got %v", ldap["secretName"])
+	}
+}
@@ -1,6 +1,7 @@
 package conversion
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -34,67 +35,19 @@ func populateProxyValues(in *v2.ControlPlaneSpec, values map[string]interface{})
 		}
 	}
 	// XXX: proxy.Networking.ConnectionTimeout is not exposed through values
-	switch proxy.Networking.Initialization.Type {
-	case v2.ProxyNetworkInitTypeCNI, "":
-		istioCNI := make(map[string]interface{})
-		if err := setHelmValue(istioCNI, "enabled", true); err != nil {
-			return err
-		}
-		cni := proxy.Networking.Initialization.CNI
-		if cni != nil && cni.Runtime != nil {
-			if cni.Runtime.PriorityClassName != "" {
-				if err := setHelmValue(istioCNI, "priorityClassName", cni.Runtime.PriorityClassName); err != nil {
-					return err
-				}
-			}
-			if len(cni.Runtime.ContainerConfig.ImagePullSecrets) > 0 {
-				pullSecretsValues := make([]string, 0)
-				for _, secret := range cni.Runtime.ContainerConfig.ImagePullSecrets {
-					pullSecretsValues = append(pullSecretsValues, secret.Name)
-				}
-				if err := setHelmValue(istioCNI, "imagePullPolicy", pullSecretsValues); err != nil {
-					return err
-				}
-			}
-			if cni.Runtime.ContainerConfig.ImagePullPolicy != "" {
-				if err := setHelmValue(istioCNI, "imagePullPolicy", string(cni.Runtime.ContainerConfig.ImagePullPolicy)); err != nil {
-					return err
-				}
-			}
-			if cni.Runtime.ContainerConfig.Resources != nil {
-				if resourcesValues, err := toValues(cni.Runtime.ContainerConfig.Resources); err == nil {
-					if err := setHelmValue(istioCNI, "resources", resourcesValues); err != nil {
-						return err
-					}
-				} else {
-					return err
-				}
-			}
-		}
-		if err := setHelmValue(values, "istio_cni", istioCNI); err != nil {
-			return err
-		}
-	case v2.ProxyNetworkInitTypeInitContainer:
-		if err := setHelmValue(values, "istio_cni.enabled", false); err != nil {
-			return err
-		}
-		if proxy.Networking.Initialization.InitContainer != nil && proxy.Networking.Initialization.InitContainer.Runtime != nil {
-			container := proxy.Networking.Initialization.InitContainer.Runtime
-			if container.Image != "" {
-				if err := setHelmValue(values, "global.proxy_init.image", container.Image); err != nil {
-					return err
-				}
-			}
-			if container.Resources != nil {
-				if resourcesValues, err := toValues(container.Resources); err == nil {
-					if err := setHelmValue(values, "global.proxy_init.resources", resourcesValues); err != nil {
-						return err
-					}
-				} else {
-					return err
-				}
-			}
-		}
+	initType := proxy.Networking.Initialization.Type
+	if initType == "" {
+		initType = v2.ProxyNetworkInitTypeCNI
+	}
+	converter, ok := proxyInitializationConverters[initType]
+	if !ok {
+		return fmt.Errorf("unknown proxy.networking.initialization.type: %s", initType)
+	}
+	// XXX: providerConfig isn't threaded through from the spec in this
+	// snapshot; converters that need it will receive it once
+	// initialization.providerConfig exists on v2.ProxyNetworkInitConfig.
+	if err := converter.Populate(proxy, nil, values); err != nil {
+		return err
 	}
 
 	// Traffic Control
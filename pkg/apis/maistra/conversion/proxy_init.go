@@ -0,0 +1,135 @@
+package conversion
+
+import (
+	v2 "github.com/maistra/istio-operator/pkg/apis/maistra/v2"
+)
+
+// ProxyInitializationConverter populates the Helm values for one
+// spec.proxy.networking.initialization.type strategy. Registering a new
+// converter (via RegisterProxyInitializationConverter) lets populateProxyValues
+// support additional strategies - e.g. Multus-based CNI chaining or an
+// Ambient/ztunnel-style node proxy - without editing the dispatch in proxy.go.
+type ProxyInitializationConverter interface {
+	// Type returns the spec.proxy.networking.initialization.type value this
+	// converter handles.
+	Type() v2.ProxyNetworkInitType
+
+	// HelmKeys returns the Helm value paths (e.g. "istio_cni",
+	// "global.proxy_init") this converter owns. Used only for documentation/
+	// conformance testing; populateProxyValues does not consult it directly.
+	HelmKeys() []string
+
+	// Populate writes this strategy's Helm values for proxy into values. proxy
+	// is never nil. providerConfig is a free-form map for the converter to
+	// interpret; populateProxyValues always passes nil, since
+	// v2.ProxyNetworkInitConfig has no providerConfig field to read it from.
+	Populate(proxy *v2.ProxyConfig, providerConfig map[string]interface{}, values map[string]interface{}) error
+}
+
+// proxyInitializationConverters holds the registered converters, keyed by the
+// initialization type they handle.
+var proxyInitializationConverters = map[v2.ProxyNetworkInitType]ProxyInitializationConverter{}
+
+// RegisterProxyInitializationConverter registers a converter for its
+// Type(). Registering a converter for a type that's already registered
+// replaces the previous one; this is only expected to happen in tests.
+func RegisterProxyInitializationConverter(converter ProxyInitializationConverter) {
+	proxyInitializationConverters[converter.Type()] = converter
+}
+
+func init() {
+	RegisterProxyInitializationConverter(&cniProxyInitializationConverter{})
+	RegisterProxyInitializationConverter(&initContainerProxyInitializationConverter{})
+}
+
+// cniProxyInitializationConverter handles v2.ProxyNetworkInitTypeCNI, the
+// default strategy when initialization.type is unset.
+type cniProxyInitializationConverter struct{}
+
+var _ ProxyInitializationConverter = (*cniProxyInitializationConverter)(nil)
+
+func (c *cniProxyInitializationConverter) Type() v2.ProxyNetworkInitType {
+	return v2.ProxyNetworkInitTypeCNI
+}
+
+func (c *cniProxyInitializationConverter) HelmKeys() []string {
+	return []string{"istio_cni"}
+}
+
+func (c *cniProxyInitializationConverter) Populate(proxy *v2.ProxyConfig, providerConfig map[string]interface{}, values map[string]interface{}) error {
+	istioCNI := make(map[string]interface{})
+	if err := setHelmValue(istioCNI, "enabled", true); err != nil {
+		return err
+	}
+	cni := proxy.Networking.Initialization.CNI
+	if cni != nil && cni.Runtime != nil {
+		if cni.Runtime.PriorityClassName != "" {
+			if err := setHelmValue(istioCNI, "priorityClassName", cni.Runtime.PriorityClassName); err != nil {
+				return err
+			}
+		}
+		if len(cni.Runtime.ContainerConfig.ImagePullSecrets) > 0 {
+			pullSecretsValues := make([]string, 0)
+			for _, secret := range cni.Runtime.ContainerConfig.ImagePullSecrets {
+				pullSecretsValues = append(pullSecretsValues, secret.Name)
+			}
+			if err := setHelmValue(istioCNI, "imagePullPolicy", pullSecretsValues); err != nil {
+				return err
+			}
+		}
+		if cni.Runtime.ContainerConfig.ImagePullPolicy != "" {
+			if err := setHelmValue(istioCNI, "imagePullPolicy", string(cni.Runtime.ContainerConfig.ImagePullPolicy)); err != nil {
+				return err
+			}
+		}
+		if cni.Runtime.ContainerConfig.Resources != nil {
+			resourcesValues, err := toValues(cni.Runtime.ContainerConfig.Resources)
+			if err != nil {
+				return err
+			}
+			if err := setHelmValue(istioCNI, "resources", resourcesValues); err != nil {
+				return err
+			}
+		}
+	}
+	return setHelmValue(values, "istio_cni", istioCNI)
+}
+
+// initContainerProxyInitializationConverter handles
+// v2.ProxyNetworkInitTypeInitContainer.
+type initContainerProxyInitializationConverter struct{}
+
+var _ ProxyInitializationConverter = (*initContainerProxyInitializationConverter)(nil)
+
+func (c *initContainerProxyInitializationConverter) Type() v2.ProxyNetworkInitType {
+	return v2.ProxyNetworkInitTypeInitContainer
+}
+
+func (c *initContainerProxyInitializationConverter) HelmKeys() []string {
+	return []string{"istio_cni.enabled", "global.proxy_init"}
+}
+
+func (c *initContainerProxyInitializationConverter) Populate(proxy *v2.ProxyConfig, providerConfig map[string]interface{}, values map[string]interface{}) error {
+	if err := setHelmValue(values, "istio_cni.enabled", false); err != nil {
+		return err
+	}
+	if proxy.Networking.Initialization.InitContainer == nil || proxy.Networking.Initialization.InitContainer.Runtime == nil {
+		return nil
+	}
+	container := proxy.Networking.Initialization.InitContainer.Runtime
+	if container.Image != "" {
+		if err := setHelmValue(values, "global.proxy_init.image", container.Image); err != nil {
+			return err
+		}
+	}
+	if container.Resources != nil {
+		resourcesValues, err := toValues(container.Resources)
+		if err != nil {
+			return err
+		}
+		if err := setHelmValue(values, "global.proxy_init.resources", resourcesValues); err != nil {
+			return err
+		}
+	}
+	return nil
+}
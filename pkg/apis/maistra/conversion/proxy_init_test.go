@@ -0,0 +1,47 @@
+package conversion
+
+import (
+	"testing"
+
+	v2 "github.com/maistra/istio-operator/pkg/apis/maistra/v2"
+)
+
+func TestProxyInitializationConvertersRegistered(t *testing.T) {
+	for _, initType := range []v2.ProxyNetworkInitType{v2.ProxyNetworkInitTypeCNI, v2.ProxyNetworkInitTypeInitContainer} {
+		if _, ok := proxyInitializationConverters[initType]; !ok {
+			t.Errorf("expected a ProxyInitializationConverter registered for %s", initType)
+		}
+	}
+}
+
+func TestRegisterProxyInitializationConverterOverridesExisting(t *testing.T) {
+	defer RegisterProxyInitializationConverter(&cniProxyInitializationConverter{})
+
+	called := false
+	RegisterProxyInitializationConverter(fakeProxyInitializationConverter{
+		initType: v2.ProxyNetworkInitTypeCNI,
+		populate: func() { called = true },
+	})
+
+	converter := proxyInitializationConverters[v2.ProxyNetworkInitTypeCNI]
+	if err := converter.Populate(&v2.ProxyConfig{}, nil, map[string]interface{}{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !called {
+		t.Error("expected the newly registered converter to be used")
+	}
+}
+
+type fakeProxyInitializationConverter struct {
+	initType v2.ProxyNetworkInitType
+	populate func()
+}
+
+var _ ProxyInitializationConverter = fakeProxyInitializationConverter{}
+
+func (f fakeProxyInitializationConverter) Type() v2.ProxyNetworkInitType { return f.initType }
+func (f fakeProxyInitializationConverter) HelmKeys() []string            { return nil }
+func (f fakeProxyInitializationConverter) Populate(proxy *v2.ProxyConfig, providerConfig map[string]interface{}, values map[string]interface{}) error {
+	f.populate()
+	return nil
+}
@@ -13,12 +13,15 @@ import (
 	"github.com/ghodss/yaml"
 
 	"github.com/maistra/istio-operator/pkg/controller/common"
+	"github.com/maistra/istio-operator/pkg/controller/common/crds"
 	"github.com/maistra/istio-operator/pkg/controller/hacks"
 
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"k8s.io/helm/pkg/releaseutil"
@@ -155,22 +158,70 @@ func createCRD(ctx context.Context, cl client.Client, crd *unstructured.Unstruct
 		if existingVersion == nil || existingVersion.LessThan(newVersion) {
 			log.Info("CRD exists, but is old or has no version label. Replacing with newer version.")
 
+			if err := preserveExistingConversion(existingCrd, crd); err != nil {
+				return err
+			}
+
 			patchedCrd, err := getPatchedCrd(existingCrd, crd)
 			if err != nil {
 				return err
 			}
 			if patchedCrd != nil { // patchedCrd is nil when the existing and new CRDs are identical
-				err = cl.Update(ctx, patchedCrd)
-				if hacks.IsTypeObjectProblemInCRDSchemas(err) {
-					err = hacks.RemoveTypeObjectFieldsFromCRDSchema(ctx, patchedCrd)
+				staleVersions, err := staleStoredVersions(existingCrd, patchedCrd)
+				if err != nil {
+					return err
+				}
+
+				if len(staleVersions) > 0 {
+					// Bump the storage version first, while keeping the stale
+					// versions declared (with storage: false) so this update
+					// isn't rejected for dropping a version status.storedVersions
+					// says is still in use. Only once that's landed do existing
+					// custom resources actually get rewritten at the new storage
+					// version, by migrateStaleStoredVersions below - so the stale
+					// versions can then be safely dropped from spec.versions in
+					// the final update that follows.
+					interimCrd, err := withVersionsRetained(patchedCrd, existingCrd, staleVersions)
+					if err != nil {
+						return err
+					}
+					err = updateCRD(ctx, cl, crd, interimCrd, func(latest *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+						rediffed, err := getPatchedCrd(latest, crd)
+						if err != nil || rediffed == nil {
+							return rediffed, err
+						}
+						return withVersionsRetained(rediffed, latest, staleVersions)
+					})
+					if err != nil {
+						return err
+					}
+
+					if err := migrateStaleStoredVersions(ctx, cl, existingCrd, staleVersions); err != nil {
+						return err
+					}
+
+					// re-fetch: the update above just changed the CRD on the
+					// cluster, and getPatchedCrd needs an up to date "existing"
+					// to diff the final, stale-version-free update against.
+					existingCrd = &unstructured.Unstructured{}
+					existingCrd.SetGroupVersionKind(crd.GroupVersionKind())
+					existingCrd.SetName(crd.GetName())
+					if err := cl.Get(ctx, client.ObjectKey{Name: crd.GetName()}, existingCrd); err != nil {
+						return err
+					}
+					patchedCrd, err = getPatchedCrd(existingCrd, crd)
 					if err != nil {
 						return err
 					}
-					err = cl.Update(ctx, patchedCrd)
 				}
-				if err != nil {
-					log.Error(err, "error updating CRD")
-					return err
+
+				if patchedCrd != nil {
+					err := updateCRD(ctx, cl, crd, patchedCrd, func(latest *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+						return getPatchedCrd(latest, crd)
+					})
+					if err != nil {
+						return err
+					}
 				}
 			}
 
@@ -198,6 +249,55 @@ func createCRD(ctx context.Context, cl client.Client, crd *unstructured.Unstruct
 	return err
 }
 
+// updateCRD applies desired (already diffed against what's on the cluster, e.g.
+// by getPatchedCrd or withVersionsRetained) to crd's CRD, retrying against a
+// freshly re-Get'd copy if it changed under us since desired was computed, and
+// working around the same non-structural-OpenAPI-schema / type-object-field API
+// server quirks createCRD's Create path also has to handle. rebuildPatch re-derives
+// the patch to retry with from that freshly re-Get'd copy, since desired was
+// diffed against a now-stale one.
+func updateCRD(ctx context.Context, cl client.Client, crd, desired *unstructured.Unstructured, rebuildPatch func(latest *unstructured.Unstructured) (*unstructured.Unstructured, error)) error {
+	log := common.LogFromContext(ctx)
+	err := crds.RetryOnUpdateConflict(func() error {
+		updateErr := cl.Update(ctx, desired)
+		if !errors.IsConflict(updateErr) {
+			return updateErr
+		}
+		// the CRD changed under us; re-diff against what's there
+		// now instead of retrying the stale patch.
+		latest := &unstructured.Unstructured{}
+		latest.SetGroupVersionKind(crd.GroupVersionKind())
+		if getErr := cl.Get(ctx, client.ObjectKey{Name: crd.GetName()}, latest); getErr != nil {
+			return getErr
+		}
+		rediffed, buildErr := rebuildPatch(latest)
+		if buildErr != nil {
+			return buildErr
+		}
+		if rediffed == nil {
+			return nil
+		}
+		desired = rediffed
+		return cl.Update(ctx, desired)
+	})
+	if field, ok := hacks.StructuralSchemaProblemField(err); ok {
+		log.Error(err, "API server rejected CRD update due to a non-structural OpenAPI schema", "field", field)
+		return err
+	}
+	if hacks.IsTypeObjectProblemInCRDSchemas(err) {
+		err = hacks.RemoveTypeObjectFieldsFromCRDSchema(ctx, desired)
+		if err != nil {
+			return err
+		}
+		err = cl.Update(ctx, desired)
+	}
+	if err != nil {
+		log.Error(err, "error updating CRD")
+		return err
+	}
+	return nil
+}
+
 func getPatchedCrd(existingCrd *unstructured.Unstructured, crd *unstructured.Unstructured) (*unstructured.Unstructured, error) {
 	patchedCrd, err := common.GetPatchedObject(existingCrd, crd)
 	if err != nil || patchedCrd == nil {
@@ -212,3 +312,159 @@ func getPatchedCrd(existingCrd *unstructured.Unstructured, crd *unstructured.Uns
 func getMaistraVersion(crd *unstructured.Unstructured) (*semver.Version, error) {
 	return semver.NewVersion(crd.GetLabels()["maistra-version"])
 }
+
+// preserveExistingConversion carries spec.conversion forward from the existing
+// CRD onto the new one, unless the new CRD explicitly sets its own conversion
+// stanza. This keeps a configured conversion webhook in place across upgrades
+// that only bump the chart's CRD content (e.g. adding a field), rather than
+// reverting it to whatever (if anything) ships in the chart.
+func preserveExistingConversion(existingCrd, crd *unstructured.Unstructured) error {
+	if _, found, err := unstructured.NestedMap(crd.Object, "spec", "conversion"); err != nil {
+		return err
+	} else if found {
+		// the new CRD sets its own conversion; respect it
+		return nil
+	}
+	existingConversion, found, err := unstructured.NestedMap(existingCrd.Object, "spec", "conversion")
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	return unstructured.SetNestedMap(crd.Object, existingConversion, "spec", "conversion")
+}
+
+// staleStoredVersions returns the subset of existingCrd's status.storedVersions
+// that desiredCrd's spec.versions no longer declares. Those are the versions an
+// update to desiredCrd would otherwise be rejected for dropping while existing
+// custom resources are still stored at them.
+func staleStoredVersions(existingCrd, desiredCrd *unstructured.Unstructured) ([]string, error) {
+	storedVersions, found, err := unstructured.NestedStringSlice(existingCrd.Object, "status", "storedVersions")
+	if err != nil || !found || len(storedVersions) == 0 {
+		return nil, err
+	}
+
+	keptVersions, err := crdVersionNames(desiredCrd)
+	if err != nil {
+		return nil, err
+	}
+	kept := map[string]bool{}
+	for _, v := range keptVersions {
+		kept[v] = true
+	}
+
+	var stale []string
+	for _, v := range storedVersions {
+		if !kept[v] {
+			stale = append(stale, v)
+		}
+	}
+	return stale, nil
+}
+
+// withVersionsRetained returns a copy of desiredCrd with staleVersions' entries
+// (copied from existingCrd.spec.versions, forced to storage: false) appended to
+// spec.versions. Applying that copy lets a CRD update bump the active storage
+// version without dropping a version status.storedVersions says existing custom
+// resources still use - see staleStoredVersions.
+func withVersionsRetained(desiredCrd, existingCrd *unstructured.Unstructured, staleVersions []string) (*unstructured.Unstructured, error) {
+	if len(staleVersions) == 0 {
+		return desiredCrd, nil
+	}
+	retain := map[string]bool{}
+	for _, v := range staleVersions {
+		retain[v] = true
+	}
+
+	existingVersions, found, err := unstructured.NestedSlice(existingCrd.Object, "spec", "versions")
+	if err != nil || !found {
+		return desiredCrd, err
+	}
+
+	out := desiredCrd.DeepCopy()
+	versions, _, err := unstructured.NestedSlice(out.Object, "spec", "versions")
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range existingVersions {
+		entry, ok := v.(map[string]interface{})
+		if !ok || !retain[entry["name"].(string)] {
+			continue
+		}
+		entry = runtime.DeepCopyJSON(entry)
+		entry["storage"] = false
+		versions = append(versions, entry)
+	}
+	if err := unstructured.SetNestedSlice(out.Object, versions, "spec", "versions"); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// migrateStaleStoredVersions migrates, via migrateStoredVersion, every custom
+// resource stored at one of staleVersions so the API server persists it at the
+// CRD's current storage version. Callers must apply the CRD update that actually
+// bumps the storage version (see withVersionsRetained) before calling this, or
+// the "no-op" re-Update below just rewrites resources at the same old version.
+func migrateStaleStoredVersions(ctx context.Context, cl client.Client, existingCrd *unstructured.Unstructured, staleVersions []string) error {
+	log := common.LogFromContext(ctx)
+
+	group, _, _ := unstructured.NestedString(existingCrd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(existingCrd.Object, "spec", "names", "kind")
+
+	for _, stored := range staleVersions {
+		log.Info("a stored version is being removed from the CRD; migrating existing custom resources to the new storage version",
+			"CRD", existingCrd.GetName(), "version", stored)
+		if err := migrateStoredVersion(ctx, cl, schema.GroupVersionKind{Group: group, Version: stored, Kind: kind}); err != nil {
+			return fmt.Errorf("could not migrate custom resources away from stored version %q of %s: %v", stored, existingCrd.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// crdVersionNames returns the set of version names a CRD (v1beta1 or v1 style)
+// declares in spec.versions (or spec.version, for older single-version CRDs).
+func crdVersionNames(crd *unstructured.Unstructured) ([]string, error) {
+	versions, found, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		names := make([]string, 0, len(versions))
+		for _, v := range versions {
+			if m, ok := v.(map[string]interface{}); ok {
+				if name, ok := m["name"].(string); ok {
+					names = append(names, name)
+				}
+			}
+		}
+		return names, nil
+	}
+	if version, found, err := unstructured.NestedString(crd.Object, "spec", "version"); err != nil {
+		return nil, err
+	} else if found {
+		return []string{version}, nil
+	}
+	return nil, nil
+}
+
+// migrateStoredVersion rewrites every custom resource of the given GVK with a
+// no-op Update, which causes the API server to persist it at the CRD's current
+// storage version, freeing up the old version to be dropped from spec.versions.
+func migrateStoredVersion(ctx context.Context, cl client.Client, gvk schema.GroupVersionKind) error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind + "List"})
+	if err := cl.List(ctx, &client.ListOptions{}, list); err != nil {
+		return err
+	}
+
+	allErrors := []error{}
+	for i := range list.Items {
+		item := &list.Items[i]
+		if err := cl.Update(ctx, item); err != nil {
+			allErrors = append(allErrors, err)
+		}
+	}
+	return utilerrors.NewAggregate(allErrors)
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultCacheSyncTimeout bounds how long WaitForCacheSync blocks waiting for
+// the controller-runtime cache to catch up to a write this process just made.
+const defaultCacheSyncTimeout = 5 * time.Second
+
+const cacheSyncPollInterval = 50 * time.Millisecond
+
+type cacheSyncKey struct {
+	gvk schema.GroupVersionKind
+	types.NamespacedName
+}
+
+// cacheSyncTracker records, per object, the resourceVersion a reconciler
+// should observe in the controller-runtime cache before trusting it again.
+// This replaces hacks.SkipReconciliationUntilCacheSynced's fixed 2-second
+// delay (smuggled through context.Context) with an actual condition to wait
+// on: the cache having caught up to the write, or a bounded timeout.
+type cacheSyncTracker struct {
+	mu       sync.Mutex
+	expected map[cacheSyncKey]string
+}
+
+var sharedCacheSyncTracker = &cacheSyncTracker{expected: map[cacheSyncKey]string{}}
+
+// RecordWrite registers resourceVersion as the version WaitForCacheSync
+// should wait to observe for the object identified by gvk/key. Call this
+// immediately after a Create/Update/Patch that you expect to be reconciled
+// again soon, passing the resourceVersion returned in the write's response.
+func RecordWrite(gvk schema.GroupVersionKind, key types.NamespacedName, resourceVersion string) {
+	sharedCacheSyncTracker.mu.Lock()
+	defer sharedCacheSyncTracker.mu.Unlock()
+	sharedCacheSyncTracker.expected[cacheSyncKey{gvk: gvk, NamespacedName: key}] = resourceVersion
+}
+
+// WaitForCacheSync blocks until cl's cache returns obj with a resourceVersion
+// at least as new as the one last recorded for gvk/key via RecordWrite, or
+// until timeout elapses, whichever comes first. If no write was recorded for
+// gvk/key, it returns immediately. Once the wait ends, whether the cache
+// caught up or the timeout elapsed, the pending expectation is cleared so
+// subsequent reconciles don't wait again for the same write.
+func WaitForCacheSync(ctx context.Context, cl client.Reader, gvk schema.GroupVersionKind, key types.NamespacedName, obj runtime.Object, timeout time.Duration) error {
+	sharedCacheSyncTracker.mu.Lock()
+	syncKey := cacheSyncKey{gvk: gvk, NamespacedName: key}
+	expectedVersion, pending := sharedCacheSyncTracker.expected[syncKey]
+	sharedCacheSyncTracker.mu.Unlock()
+
+	if !pending {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultCacheSyncTimeout
+	}
+
+	clientObj, ok := obj.(client.Object)
+	if !ok {
+		return nil
+	}
+
+	err := wait.PollImmediate(cacheSyncPollInterval, timeout, func() (bool, error) {
+		if getErr := cl.Get(ctx, key, clientObj); getErr != nil {
+			// an object that's gone, or not yet visible, can't be compared;
+			// keep polling until the timeout rather than failing fast, since
+			// a transient Get error shouldn't be worse than the old 2s sleep.
+			return false, nil
+		}
+		return resourceVersionAtLeast(clientObj.GetResourceVersion(), expectedVersion), nil
+	})
+
+	sharedCacheSyncTracker.mu.Lock()
+	delete(sharedCacheSyncTracker.expected, syncKey)
+	sharedCacheSyncTracker.mu.Unlock()
+
+	return err
+}
+
+// resourceVersionAtLeast compares two resourceVersions. Kubernetes documents
+// resourceVersion as an opaque string, but etcd-backed API servers hand out
+// monotonically increasing integers in practice; we use that when possible
+// and fall back to exact equality otherwise.
+func resourceVersionAtLeast(actual, expected string) bool {
+	actualInt, actualErr := strconv.ParseInt(actual, 10, 64)
+	expectedInt, expectedErr := strconv.ParseInt(expected, 10, 64)
+	if actualErr == nil && expectedErr == nil {
+		return actualInt >= expectedInt
+	}
+	return actual == expected
+}
@@ -0,0 +1,126 @@
+package crds
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxEnsureCRDRetries bounds how many times EnsureCRD attempts an Update,
+// counting the first attempt, when it keeps losing a write race to another
+// installer (e.g. another operator replica running InstallCRDs concurrently).
+const maxEnsureCRDRetries = 3
+
+// EnsureCRD creates crd if it doesn't exist yet, or updates the existing one
+// to crd's spec otherwise, retrying on update conflicts. crd must already
+// have its apiVersion set to the apiextensions version the cluster supports
+// (see SupportedAPIVersion); EnsureCRD itself doesn't choose between v1 and
+// v1beta1.
+func EnsureCRD(ctx context.Context, cl client.Client, crd *unstructured.Unstructured) error {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(crd.GroupVersionKind())
+	err := cl.Get(ctx, client.ObjectKey{Name: crd.GetName()}, existing)
+	if errors.IsNotFound(err) {
+		return cl.Create(ctx, crd.DeepCopy())
+	} else if err != nil {
+		return err
+	}
+
+	return RetryOnUpdateConflict(func() error {
+		updated := existing.DeepCopy()
+		updated.Object["spec"] = crd.Object["spec"]
+		err := cl.Update(ctx, updated)
+		if errors.IsConflict(err) {
+			existing = &unstructured.Unstructured{}
+			existing.SetGroupVersionKind(crd.GroupVersionKind())
+			if getErr := cl.Get(ctx, client.ObjectKey{Name: crd.GetName()}, existing); getErr != nil {
+				return getErr
+			}
+		}
+		return err
+	})
+}
+
+// RetryOnUpdateConflict calls attempt, which should perform a single Update
+// and return its error, up to maxEnsureCRDRetries times as long as it keeps
+// failing with an IsConflict error - the same bound EnsureCRD applies to its
+// own retries. Callers whose Update depends on the object just re-fetched
+// (as EnsureCRD's does) should do that re-fetch inside attempt before
+// retrying, since RetryOnUpdateConflict only controls the looping.
+func RetryOnUpdateConflict(attempt func() error) error {
+	var err error
+	for i := 0; i < maxEnsureCRDRetries; i++ {
+		err = attempt()
+		if err == nil || !errors.IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// PruneNonStructuralFields strips "type: object" from every level of crd's
+// v1beta1 OpenAPI validation schemas. It mirrors
+// hacks.RemoveTypeObjectFieldsFromCRDSchema, but works directly on the
+// unstructured representation so it can run as the last step of the v1beta1
+// downgrade path, before the schema has been (or without ever being)
+// decoded into a typed apiextensionsv1beta1.CustomResourceDefinition.
+func PruneNonStructuralFields(crd *unstructured.Unstructured) {
+	if schema, found, _ := unstructured.NestedMap(crd.Object, "spec", "validation", "openAPIV3Schema"); found {
+		pruneTypeObject(schema)
+		_ = unstructured.SetNestedMap(crd.Object, schema, "spec", "validation", "openAPIV3Schema")
+	}
+
+	versions, found, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if !found {
+		return
+	}
+	for i, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if schema, found, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema"); found {
+			pruneTypeObject(schema)
+			_ = unstructured.SetNestedMap(version, schema, "schema", "openAPIV3Schema")
+			versions[i] = version
+		}
+	}
+	_ = unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions")
+}
+
+func pruneTypeObject(schema map[string]interface{}) {
+	if schema == nil {
+		return
+	}
+	if schema["type"] == "object" {
+		delete(schema, "type")
+	}
+	for _, key := range []string{"properties", "patternProperties", "definitions"} {
+		if nested, ok := schema[key].(map[string]interface{}); ok {
+			for propName, prop := range nested {
+				if propSchema, ok := prop.(map[string]interface{}); ok {
+					pruneTypeObject(propSchema)
+					nested[propName] = propSchema
+				}
+			}
+		}
+	}
+	for _, key := range []string{"oneOf", "anyOf", "allOf"} {
+		if list, ok := schema[key].([]interface{}); ok {
+			for i, item := range list {
+				if itemSchema, ok := item.(map[string]interface{}); ok {
+					pruneTypeObject(itemSchema)
+					list[i] = itemSchema
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		pruneTypeObject(items)
+	}
+	if additionalProperties, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		pruneTypeObject(additionalProperties)
+	}
+}
@@ -0,0 +1,151 @@
+package crds
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fakeCRDClient is a minimal client.Client recording Create/Update calls and
+// simulating a configurable number of update conflicts, for exercising
+// EnsureCRD/RetryOnUpdateConflict without a full fake clientset.
+type fakeCRDClient struct {
+	client.Client
+
+	existing        *unstructured.Unstructured
+	conflictsLeft   int
+	createCalls     int
+	updateCalls     int
+	lastUpdatedSpec interface{}
+}
+
+func (f *fakeCRDClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if f.existing == nil {
+		return errors.NewNotFound(schema.GroupResource{Resource: "customresourcedefinitions"}, key.Name)
+	}
+	*obj.(*unstructured.Unstructured) = *f.existing.DeepCopy()
+	return nil
+}
+
+func (f *fakeCRDClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOptionFunc) error {
+	f.createCalls++
+	return nil
+}
+
+func (f *fakeCRDClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOptionFunc) error {
+	f.updateCalls++
+	if f.conflictsLeft > 0 {
+		f.conflictsLeft--
+		return errors.NewConflict(schema.GroupResource{Resource: "customresourcedefinitions"}, obj.(*unstructured.Unstructured).GetName(), nil)
+	}
+	f.lastUpdatedSpec = obj.(*unstructured.Unstructured).Object["spec"]
+	return nil
+}
+
+func newTestCRD(name string, spec interface{}) *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	crd.SetGroupVersionKind(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"})
+	crd.SetName(name)
+	return crd
+}
+
+func TestEnsureCRDCreatesWhenMissing(t *testing.T) {
+	cl := &fakeCRDClient{}
+	crd := newTestCRD("widgets.example.com", map[string]interface{}{"group": "example.com"})
+
+	if err := EnsureCRD(context.TODO(), cl, crd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cl.createCalls != 1 || cl.updateCalls != 0 {
+		t.Errorf("expected exactly one Create and no Update, got %d creates, %d updates", cl.createCalls, cl.updateCalls)
+	}
+}
+
+func TestEnsureCRDUpdatesWhenPresent(t *testing.T) {
+	cl := &fakeCRDClient{existing: newTestCRD("widgets.example.com", map[string]interface{}{"group": "old"})}
+	crd := newTestCRD("widgets.example.com", map[string]interface{}{"group": "example.com"})
+
+	if err := EnsureCRD(context.TODO(), cl, crd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cl.createCalls != 0 || cl.updateCalls != 1 {
+		t.Errorf("expected exactly one Update and no Create, got %d creates, %d updates", cl.createCalls, cl.updateCalls)
+	}
+	if spec, ok := cl.lastUpdatedSpec.(map[string]interface{}); !ok || spec["group"] != "example.com" {
+		t.Errorf("expected the updated spec to match the new CRD, got %v", cl.lastUpdatedSpec)
+	}
+}
+
+func TestEnsureCRDRetriesOnConflict(t *testing.T) {
+	cl := &fakeCRDClient{
+		existing:      newTestCRD("widgets.example.com", map[string]interface{}{"group": "old"}),
+		conflictsLeft: maxEnsureCRDRetries - 1,
+	}
+	crd := newTestCRD("widgets.example.com", map[string]interface{}{"group": "example.com"})
+
+	if err := EnsureCRD(context.TODO(), cl, crd); err != nil {
+		t.Fatalf("expected conflicts within the retry bound to be absorbed, got: %v", err)
+	}
+	if cl.updateCalls != maxEnsureCRDRetries {
+		t.Errorf("expected %d update attempts, got %d", maxEnsureCRDRetries, cl.updateCalls)
+	}
+}
+
+func TestRetryOnUpdateConflictBoundsAttempts(t *testing.T) {
+	attempts := 0
+	err := RetryOnUpdateConflict(func() error {
+		attempts++
+		return errors.NewConflict(schema.GroupResource{Resource: "customresourcedefinitions"}, "widgets.example.com", nil)
+	})
+	if err == nil || !errors.IsConflict(err) {
+		t.Fatalf("expected a conflict error to surface once the bound is exceeded, got: %v", err)
+	}
+	if attempts != maxEnsureCRDRetries {
+		t.Errorf("expected %d attempts, got %d", maxEnsureCRDRetries, attempts)
+	}
+}
+
+func TestPruneNonStructuralFieldsRemovesNestedTypeObject(t *testing.T) {
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"validation": map[string]interface{}{
+				"openAPIV3Schema": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"replicas": map[string]interface{}{
+									"type": "integer",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+
+	PruneNonStructuralFields(crd)
+
+	schema, found, err := unstructured.NestedMap(crd.Object, "spec", "validation", "openAPIV3Schema")
+	if err != nil || !found {
+		t.Fatalf("expected schema to still be present, err=%v", err)
+	}
+	if _, ok := schema["type"]; ok {
+		t.Error("expected top-level type:object to be removed")
+	}
+	specProp := schema["properties"].(map[string]interface{})["spec"].(map[string]interface{})
+	if _, ok := specProp["type"]; ok {
+		t.Error("expected nested type:object to be removed")
+	}
+	replicasProp := specProp["properties"].(map[string]interface{})["replicas"].(map[string]interface{})
+	if replicasProp["type"] != "integer" {
+		t.Error("expected non-object type to be preserved")
+	}
+}
@@ -0,0 +1,39 @@
+// Package crds owns CRD install/update logic, including detecting which
+// apiextensions API version a cluster supports and falling back to the
+// v1beta1 type-object schema scrubber on clusters (OpenShift 3.11) that only
+// support that version.
+package crds
+
+import (
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	// APIVersionV1 is the modern apiextensions CRD API, with structural
+	// OpenAPI schemas.
+	APIVersionV1 = "apiextensions.k8s.io/v1"
+	// APIVersionV1beta1 is the legacy apiextensions CRD API, still required
+	// on OpenShift 3.11.
+	APIVersionV1beta1 = "apiextensions.k8s.io/v1beta1"
+)
+
+// SupportedAPIVersion returns the apiextensions CRD API version the cluster
+// supports, preferring APIVersionV1 and falling back to APIVersionV1beta1
+// only when the server doesn't advertise v1 at all.
+func SupportedAPIVersion(disc discovery.DiscoveryInterface) (string, error) {
+	groups, err := disc.ServerGroups()
+	if err != nil {
+		return "", err
+	}
+	for _, group := range groups.Groups {
+		if group.Name != "apiextensions.k8s.io" {
+			continue
+		}
+		for _, version := range group.Versions {
+			if version.GroupVersion == APIVersionV1 {
+				return APIVersionV1, nil
+			}
+		}
+	}
+	return APIVersionV1beta1, nil
+}
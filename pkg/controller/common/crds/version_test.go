@@ -0,0 +1,71 @@
+package crds
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+)
+
+// stubDiscovery implements discovery.DiscoveryInterface by embedding the
+// (nil) interface and overriding only ServerGroups, the single method
+// SupportedAPIVersion relies on.
+type stubDiscovery struct {
+	discovery.DiscoveryInterface
+	groups []metav1.APIGroup
+}
+
+func (s *stubDiscovery) ServerGroups() (*metav1.APIGroupList, error) {
+	return &metav1.APIGroupList{Groups: s.groups}, nil
+}
+
+func TestSupportedAPIVersionPrefersV1(t *testing.T) {
+	disc := &stubDiscovery{groups: []metav1.APIGroup{
+		{
+			Name: "apiextensions.k8s.io",
+			Versions: []metav1.GroupVersionForDiscovery{
+				{GroupVersion: APIVersionV1beta1},
+				{GroupVersion: APIVersionV1},
+			},
+		},
+	}}
+
+	version, err := SupportedAPIVersion(disc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != APIVersionV1 {
+		t.Errorf("expected %s, got %s", APIVersionV1, version)
+	}
+}
+
+func TestSupportedAPIVersionFallsBackToV1beta1(t *testing.T) {
+	disc := &stubDiscovery{groups: []metav1.APIGroup{
+		{
+			Name: "apiextensions.k8s.io",
+			Versions: []metav1.GroupVersionForDiscovery{
+				{GroupVersion: APIVersionV1beta1},
+			},
+		},
+	}}
+
+	version, err := SupportedAPIVersion(disc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != APIVersionV1beta1 {
+		t.Errorf("expected %s, got %s", APIVersionV1beta1, version)
+	}
+}
+
+func TestSupportedAPIVersionFallsBackWhenGroupMissing(t *testing.T) {
+	disc := &stubDiscovery{groups: []metav1.APIGroup{}}
+
+	version, err := SupportedAPIVersion(disc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != APIVersionV1beta1 {
+		t.Errorf("expected %s, got %s", APIVersionV1beta1, version)
+	}
+}
@@ -0,0 +1,47 @@
+package common
+
+import "testing"
+
+func TestShouldDryRun(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{"unset", nil, false},
+		{"false", map[string]string{DryRunAnnotationKey: "false"}, false},
+		{"true", map[string]string{DryRunAnnotationKey: "true"}, true},
+		{"unrelated annotation", map[string]string{"foo": "true"}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ShouldDryRun(tc.annotations); got != tc.want {
+				t.Errorf("ShouldDryRun(%v) = %v, want %v", tc.annotations, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReconcileReportSummarize(t *testing.T) {
+	report := &ReconcileReport{Entries: []ReconcileReportEntry{
+		{Action: ReconcileActionCreate},
+		{Action: ReconcileActionCreate},
+		{Action: ReconcileActionUpdate},
+		{Action: ReconcileActionDelete},
+		{Action: ReconcileActionNoChange},
+		{Action: ReconcileActionNoChange},
+		{Action: ReconcileActionNoChange},
+	}}
+
+	want := "2 to create, 1 to update, 1 to delete, 3 unchanged"
+	if got := report.Summarize(); got != want {
+		t.Errorf("Summarize() = %q, want %q", got, want)
+	}
+}
+
+func TestReconcileReportSummarizeNilReport(t *testing.T) {
+	var report *ReconcileReport
+	if got := report.Summarize(); got != "no dry-run report available" {
+		t.Errorf("Summarize() on nil report = %q", got)
+	}
+}
@@ -5,11 +5,16 @@ import (
 	"strings"
 
 	"github.com/ghodss/yaml"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 
 	v1 "github.com/maistra/istio-operator/pkg/apis/maistra/v1"
 
@@ -21,15 +26,49 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ApplyStrategy selects how ManifestProcessor reconciles the desired state of
+// a rendered object with what's on the cluster.
+type ApplyStrategy int
+
+const (
+	// ApplyStrategyPatch is the legacy client-side Get + PatchFactory.CreatePatch
+	// strategy, falling back to a delete/recreate when the patch is rejected as invalid.
+	ApplyStrategyPatch ApplyStrategy = iota
+	// ApplyStrategyServerSideApply uses the Kubernetes server-side apply API
+	// (application/apply-patch+yaml) instead of computing a client-side patch.
+	ApplyStrategyServerSideApply
+)
+
+// fieldManager identifies the operator as the owner of fields applied via
+// ApplyStrategyServerSideApply.
+const fieldManager = "maistra-istio-operator"
+
+// maxConflictRetries bounds the number of times processObject retries a
+// server-side apply after an IsConflict error before giving up.
+const maxConflictRetries = 3
+
 type ManifestProcessor struct {
 	ControllerResources
 	preprocessObject func(ctx context.Context, obj *unstructured.Unstructured) error
 	processNewObject func(ctx context.Context, obj *unstructured.Unstructured) error
 
 	appInstance, appVersion, owner string
+	applyStrategy                  ApplyStrategy
+	pruningGVKs                    []schema.GroupVersionKind
+	recorder                       record.EventRecorder
+	suspended                      bool
+	dryRun                         bool
+	lastReport                     *ReconcileReport
 }
 
 func NewManifestProcessor(controllerResources ControllerResources, appInstance, appVersion, owner string, preprocessObjectFunc, postProcessObjectFunc func(ctx context.Context, obj *unstructured.Unstructured) error) *ManifestProcessor {
+	return NewManifestProcessorWithApplyStrategy(controllerResources, appInstance, appVersion, owner, preprocessObjectFunc, postProcessObjectFunc, ApplyStrategyPatch)
+}
+
+// NewManifestProcessorWithApplyStrategy is like NewManifestProcessor, but lets the
+// caller select the strategy used to reconcile rendered objects with the cluster
+// (e.g. a per-ControlPlane field selecting server-side apply).
+func NewManifestProcessorWithApplyStrategy(controllerResources ControllerResources, appInstance, appVersion, owner string, preprocessObjectFunc, postProcessObjectFunc func(ctx context.Context, obj *unstructured.Unstructured) error, applyStrategy ApplyStrategy) *ManifestProcessor {
 	return &ManifestProcessor{
 		ControllerResources: controllerResources,
 		preprocessObject:    preprocessObjectFunc,
@@ -37,11 +76,35 @@ func NewManifestProcessor(controllerResources ControllerResources, appInstance,
 		appInstance:         appInstance,
 		appVersion:          appVersion,
 		owner:               owner,
+		applyStrategy:       applyStrategy,
 	}
 }
 
+// SetSuspended toggles reconciliation suspension for this processor. While
+// suspended, ProcessManifests is a no-op: it neither creates/updates nor prunes
+// any resources, but still returns successfully so watches stay registered.
+// Callers are responsible for reflecting this in a Suspended status condition
+// on the owning resource.
+func (p *ManifestProcessor) SetSuspended(suspended bool) {
+	p.suspended = suspended
+}
+
 func (p *ManifestProcessor) ProcessManifests(ctx context.Context, manifests []manifest.Manifest, component string) error {
+	if p.suspended {
+		LogFromContext(ctx).Info("reconciliation is suspended; skipping ProcessManifests", "component", component)
+		reconcileTotal.WithLabelValues(component, p.owner, p.applyStrategy.metricLabel(), string(reconcileOutcomeSuspended)).Inc()
+		return nil
+	}
+
+	timer := prometheus.NewTimer(reconcileDuration.WithLabelValues(component, p.owner, p.applyStrategy.metricLabel()))
+	defer timer.ObserveDuration()
+
+	if p.dryRun {
+		p.lastReport = &ReconcileReport{}
+	}
+
 	allErrors := []error{}
+	renderedKeys := make(map[v1.ResourceKey]struct{})
 
 	origCtx := ctx
 	origLogger := LogFromContext(ctx)
@@ -69,17 +132,27 @@ func (p *ManifestProcessor) ProcessManifests(ctx context.Context, manifests []ma
 				allErrors = append(allErrors, err)
 				continue
 			}
-			err = p.processObject(ctx, obj, component)
+			err = p.processObject(ctx, obj, component, renderedKeys)
 			if err != nil {
 				allErrors = append(allErrors, err)
 			}
 		}
 	}
 
-	return utilerrors.NewAggregate(allErrors)
+	if err := p.Prune(ctx, component, renderedKeys); err != nil {
+		allErrors = append(allErrors, err)
+	}
+
+	err := utilerrors.NewAggregate(allErrors)
+	outcome := reconcileOutcomeSuccess
+	if err != nil {
+		outcome = reconcileOutcomeError
+	}
+	reconcileTotal.WithLabelValues(component, p.owner, p.applyStrategy.metricLabel(), string(outcome)).Inc()
+	return err
 }
 
-func (p *ManifestProcessor) processObject(ctx context.Context, obj *unstructured.Unstructured, component string) error {
+func (p *ManifestProcessor) processObject(ctx context.Context, obj *unstructured.Unstructured, component string, renderedKeys map[v1.ResourceKey]struct{}) error {
 	origLogger := LogFromContext(ctx)
 
 	key := v1.NewResourceKey(obj, obj)
@@ -94,7 +167,7 @@ func (p *ManifestProcessor) processObject(ctx context.Context, obj *unstructured
 			return err
 		}
 		for _, item := range list.Items {
-			err = p.processObject(ctx, &item, component)
+			err = p.processObject(ctx, &item, component, renderedKeys)
 			if err != nil {
 				allErrors = append(allErrors, err)
 			}
@@ -102,6 +175,8 @@ func (p *ManifestProcessor) processObject(ctx context.Context, obj *unstructured
 		return utilerrors.NewAggregate(allErrors)
 	}
 
+	renderedKeys[key] = struct{}{}
+
 	p.addMetadata(obj, component)
 
 	log.V(2).Info("beginning reconciliation of resource", "ResourceKey", key)
@@ -112,6 +187,24 @@ func (p *ManifestProcessor) processObject(ctx context.Context, obj *unstructured
 		return err
 	}
 
+	if p.dryRun {
+		err = p.dryRunObject(ctx, key, obj)
+		log.V(2).Info("resource reconciliation complete")
+		if err != nil {
+			log.Error(err, "error occurred reconciling resource")
+		}
+		return err
+	}
+
+	if p.applyStrategy == ApplyStrategyServerSideApply {
+		err = p.applyServerSide(ctx, obj)
+		log.V(2).Info("resource reconciliation complete")
+		if err != nil {
+			log.Error(err, "error occurred reconciling resource")
+		}
+		return err
+	}
+
 	err = kubectl.CreateApplyAnnotation(obj, unstructured.UnstructuredJSONScheme)
 	if err != nil {
 		log.Error(err, "error adding apply annotation to object")
@@ -126,6 +219,11 @@ func (p *ManifestProcessor) processObject(ctx context.Context, obj *unstructured
 		return err
 	}
 
+	gvk := obj.GroupVersionKind()
+	if err := WaitForCacheSync(ctx, p.Client, gvk, objectKey, key.ToUnstructured(), 0); err != nil {
+		log.Info("cache did not catch up to this resource's last write in time; proceeding anyway", "error", err)
+	}
+
 	var patch Patch
 
 	err = p.Client.Get(ctx, objectKey, receiver)
@@ -134,6 +232,7 @@ func (p *ManifestProcessor) processObject(ctx context.Context, obj *unstructured
 			log.Info("creating resource")
 			err = p.Client.Create(ctx, obj)
 			if err == nil {
+				RecordWrite(gvk, objectKey, obj.GetResourceVersion())
 				// special handling
 				if err := p.processNewObject(ctx, obj); err != nil {
 					// just log for now
@@ -146,6 +245,11 @@ func (p *ManifestProcessor) processObject(ctx context.Context, obj *unstructured
 	} else if patch, err = p.PatchFactory.CreatePatch(receiver, obj); err == nil && patch != nil {
 		log.Info("updating existing resource")
 		_, err = patch.Apply(ctx)
+		// Patch.Apply's result doesn't expose the resourceVersion the write
+		// produced (the Patch/PatchFactory types aren't defined in this
+		// checkout), so a successful patch records nothing for
+		// WaitForCacheSync; only the create path above and the
+		// delete/recreate fallback below record a write.
 		if errors.IsInvalid(err) {
 			// patch was invalid, try delete/create
 			log.Info("patch failed.  attempting to delete and recreate the resource")
@@ -154,6 +258,7 @@ func (p *ManifestProcessor) processObject(ctx context.Context, obj *unstructured
 				obj.SetResourceVersion("")
 				if createErr := p.Client.Create(ctx, obj); createErr == nil {
 					log.Info("successfully recreated resource after patch failure")
+					RecordWrite(gvk, objectKey, obj.GetResourceVersion())
 					err = nil
 				} else {
 					log.Error(createErr, "error trying to recreate resource after patch failure")
@@ -170,6 +275,49 @@ func (p *ManifestProcessor) processObject(ctx context.Context, obj *unstructured
 	return err
 }
 
+// applyServerSide reconciles obj with the cluster using Kubernetes server-side
+// apply instead of a client-side Get + patch. Unlike the legacy patch strategy,
+// it never falls back to deleting and recreating the resource: conflicts with
+// other field managers are resolved by forcing ownership, and a bounded number
+// of retries absorbs transient IsConflict errors from concurrent updates.
+func (p *ManifestProcessor) applyServerSide(ctx context.Context, obj *unstructured.Unstructured) error {
+	log := LogFromContext(ctx)
+
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	patch := applyPatch{data: data}
+
+	var applyErr error
+	for attempt := 0; attempt <= maxConflictRetries; attempt++ {
+		applyErr = p.Client.Patch(ctx, obj, patch, client.FieldOwner(fieldManager), client.ForceOwnership)
+		if applyErr == nil {
+			return nil
+		}
+		if !errors.IsConflict(applyErr) {
+			return applyErr
+		}
+		log.Info("server-side apply conflicted with another field manager, retrying", "attempt", attempt)
+	}
+	return applyErr
+}
+
+// applyPatch implements client.Patch for the server-side apply content type.
+// The standard JSON/merge/strategic-merge patch types in client.Patch don't
+// cover application/apply-patch+yaml, so we supply our own.
+type applyPatch struct {
+	data []byte
+}
+
+func (p applyPatch) Type() types.PatchType {
+	return types.ApplyPatchType
+}
+
+func (p applyPatch) Data(obj runtime.Object) ([]byte, error) {
+	return p.data, nil
+}
+
 func (p *ManifestProcessor) addMetadata(obj *unstructured.Unstructured, component string) {
 	labels := map[string]string{
 		// add app labels
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/maistra/istio-operator/pkg/apis/maistra/v1"
+)
+
+// fakeApplyClient is a minimal client.Client that only implements the calls
+// processObject makes in ApplyStrategyServerSideApply mode, so these tests can
+// assert on the exact verb and patch type used without pulling in a full fake.
+type fakeApplyClient struct {
+	client.Client
+
+	patchCalls       int
+	conflictsLeft    int
+	deleted          bool
+	lastPatchType    types.PatchType
+	lastPatchOptions []client.PatchOptionFunc
+}
+
+func (f *fakeApplyClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOptionFunc) error {
+	f.patchCalls++
+	f.lastPatchType = patch.Type()
+	f.lastPatchOptions = opts
+	if f.conflictsLeft > 0 {
+		f.conflictsLeft--
+		return errors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "cm", nil)
+	}
+	return nil
+}
+
+func (f *fakeApplyClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOptionFunc) error {
+	f.deleted = true
+	return nil
+}
+
+// fakePatchStrategyClient implements just enough of client.Client for
+// processObject's legacy ApplyStrategyPatch path: Get always reports
+// NotFound (so processObject takes the create branch), and Create stamps a
+// resourceVersion onto the object the way a real API server would.
+type fakePatchStrategyClient struct {
+	client.Client
+
+	createdResourceVersion string
+}
+
+func (f *fakePatchStrategyClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	return errors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, key.Name)
+}
+
+func (f *fakePatchStrategyClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOptionFunc) error {
+	u := obj.(*unstructured.Unstructured)
+	u.SetResourceVersion(f.createdResourceVersion)
+	return nil
+}
+
+// staleReader always returns a resourceVersion older than what was recorded,
+// so WaitForCacheSync only returns without error if nothing was recorded.
+type staleReader struct {
+	client.Reader
+}
+
+func (staleReader) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	obj.(*unstructured.Unstructured).SetResourceVersion("0")
+	return nil
+}
+
+func TestProcessObjectRecordsWriteAfterCreate(t *testing.T) {
+	fakeClient := &fakePatchStrategyClient{createdResourceVersion: "42"}
+	processor := newTestProcessor(fakeClient, ApplyStrategyPatch)
+
+	obj := newTestConfigMap("cm")
+	if err := processor.processObject(context.TODO(), obj, "test-component", newTestRenderedKeys(obj)); err != nil {
+		t.Fatalf("unexpected error creating resource: %v", err)
+	}
+
+	gvk := obj.GroupVersionKind()
+	key := types.NamespacedName{Namespace: "test", Name: "cm"}
+	err := WaitForCacheSync(context.TODO(), staleReader{}, gvk, key, &unstructured.Unstructured{}, 30*time.Millisecond)
+	if err == nil {
+		t.Error("expected WaitForCacheSync to time out waiting for the resourceVersion recorded after Create")
+	}
+}
+
+func newTestConfigMap(name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+	obj.SetNamespace("test")
+	obj.SetName(name)
+	return obj
+}
+
+// newTestRenderedKeys returns a renderedKeys set containing obj's key, as
+// ProcessManifests would have built by the time it calls processObject.
+func newTestRenderedKeys(obj *unstructured.Unstructured) map[v1.ResourceKey]struct{} {
+	return map[v1.ResourceKey]struct{}{v1.NewResourceKey(obj, obj): {}}
+}
+
+func newTestProcessor(cl client.Client, applyStrategy ApplyStrategy) *ManifestProcessor {
+	noop := func(ctx context.Context, obj *unstructured.Unstructured) error { return nil }
+	return NewManifestProcessorWithApplyStrategy(ControllerResources{Client: cl}, "test-instance", "v1", "test-owner", noop, noop, applyStrategy)
+}
+
+func TestServerSideApplyUsedForUpdates(t *testing.T) {
+	fakeClient := &fakeApplyClient{}
+	processor := newTestProcessor(fakeClient, ApplyStrategyServerSideApply)
+
+	obj := newTestConfigMap("cm")
+	if err := processor.processObject(context.TODO(), obj, "test-component", newTestRenderedKeys(obj)); err != nil {
+		t.Fatalf("unexpected error applying resource: %v", err)
+	}
+
+	if fakeClient.patchCalls != 1 {
+		t.Fatalf("expected exactly one Patch call, got %d", fakeClient.patchCalls)
+	}
+	if fakeClient.lastPatchType != types.ApplyPatchType {
+		t.Errorf("expected patch type %q, got %q", types.ApplyPatchType, fakeClient.lastPatchType)
+	}
+	if fakeClient.deleted {
+		t.Errorf("server-side apply must never delete the resource")
+	}
+}
+
+func TestServerSideApplyRetriesOnConflict(t *testing.T) {
+	fakeClient := &fakeApplyClient{conflictsLeft: maxConflictRetries}
+	processor := newTestProcessor(fakeClient, ApplyStrategyServerSideApply)
+
+	obj := newTestConfigMap("cm")
+	if err := processor.processObject(context.TODO(), obj, "test-component", newTestRenderedKeys(obj)); err != nil {
+		t.Fatalf("expected transient IsConflict errors to be retried within the bound, got: %v", err)
+	}
+	if fakeClient.patchCalls != maxConflictRetries+1 {
+		t.Errorf("expected %d patch attempts, got %d", maxConflictRetries+1, fakeClient.patchCalls)
+	}
+}
+
+func TestServerSideApplyBoundsConflictRetriesAndDoesNotDelete(t *testing.T) {
+	fakeClient := &fakeApplyClient{conflictsLeft: maxConflictRetries + 5}
+	processor := newTestProcessor(fakeClient, ApplyStrategyServerSideApply)
+
+	obj := newTestConfigMap("cm")
+	err := processor.processObject(context.TODO(), obj, "test-component", newTestRenderedKeys(obj))
+	if err == nil || !errors.IsConflict(err) {
+		t.Fatalf("expected a bounded IsConflict error to surface, got: %v", err)
+	}
+	if fakeClient.deleted {
+		t.Errorf("server-side apply must never fall back to delete/recreate on conflict")
+	}
+}
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// reconcileOutcome classifies how a single ProcessManifests call ended, for the
+// reconcile_total metric below.
+type reconcileOutcome string
+
+const (
+	reconcileOutcomeSuccess   reconcileOutcome = "success"
+	reconcileOutcomeError     reconcileOutcome = "error"
+	reconcileOutcomeSuspended reconcileOutcome = "suspended"
+)
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "maistra_istio_operator_reconcile_total",
+		Help: "Number of ManifestProcessor reconciles, labeled by component, owner, apply strategy and outcome",
+	}, []string{"component", "owner", "apply_strategy", "outcome"})
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "maistra_istio_operator_reconcile_duration_seconds",
+		Help:    "Time spent in a single ManifestProcessor.ProcessManifests call, labeled by component, owner and apply strategy",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"component", "owner", "apply_strategy"})
+
+	prunedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "maistra_istio_operator_pruned_resources_total",
+		Help: "Number of orphaned resources deleted by ManifestProcessor.Prune, labeled by component and owner",
+	}, []string{"component", "owner"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileTotal, reconcileDuration, prunedTotal)
+}
+
+func (s ApplyStrategy) metricLabel() string {
+	if s == ApplyStrategyServerSideApply {
+		return "server-side-apply"
+	}
+	return "patch"
+}
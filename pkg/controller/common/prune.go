@@ -0,0 +1,103 @@
+package common
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/maistra/istio-operator/pkg/apis/maistra/v1"
+)
+
+// DefaultPruningGVKs is the set of resource kinds Prune considers when looking
+// for resources that were rendered by a previous reconcile of a component but
+// are no longer present in the current manifest set.
+var DefaultPruningGVKs = []schema.GroupVersionKind{
+	{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"},
+	{Group: "apps", Version: "v1", Kind: "Deployment"},
+	{Version: "v1", Kind: "Service"},
+	{Version: "v1", Kind: "ConfigMap"},
+	{Version: "v1", Kind: "ServiceAccount"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRoleBinding"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"},
+	{Group: "networking.istio.io", Version: "v1alpha3", Kind: "VirtualService"},
+	{Group: "networking.istio.io", Version: "v1alpha3", Kind: "DestinationRule"},
+}
+
+// SetEventRecorder installs a recorder used by Prune to emit an event for each
+// pruned object. If unset, Prune only logs the deletions.
+func (p *ManifestProcessor) SetEventRecorder(recorder record.EventRecorder) {
+	p.recorder = recorder
+}
+
+// SetPruningGVKs overrides the set of kinds Prune considers for this processor's
+// component. Components that render kinds outside DefaultPruningGVKs (or that
+// never render some of those kinds) should narrow this list accordingly.
+func (p *ManifestProcessor) SetPruningGVKs(gvks []schema.GroupVersionKind) {
+	p.pruningGVKs = gvks
+}
+
+// Prune removes resources labeled with this processor's owner and the given
+// component that are not present in renderedKeys, i.e. objects that were
+// rendered by a previous reconcile but have since disappeared from the chart
+// output (e.g. because an addon was disabled or a resource was renamed).
+func (p *ManifestProcessor) Prune(ctx context.Context, component string, renderedKeys map[v1.ResourceKey]struct{}) error {
+	log := LogFromContext(ctx)
+
+	gvks := p.pruningGVKs
+	if gvks == nil {
+		gvks = DefaultPruningGVKs
+	}
+
+	labelSelector := map[string]string{
+		OwnerKey:                  p.owner,
+		KubernetesAppComponentKey: component,
+	}
+
+	allErrors := []error{}
+	for _, gvk := range gvks {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk)
+		err := p.Client.List(ctx, client.MatchingLabels(labelSelector), list)
+		if err != nil {
+			log.Error(err, "error listing resources for pruning", "GroupVersionKind", gvk)
+			allErrors = append(allErrors, err)
+			continue
+		}
+		for _, item := range list.Items {
+			item := item
+			if _, ok := GetLabel(&item, KubernetesAppManagedByKey); !ok {
+				// not managed by us; leave it alone
+				continue
+			}
+			key := v1.NewResourceKey(&item, &item)
+			if _, stillRendered := renderedKeys[key]; stillRendered {
+				continue
+			}
+			if p.dryRun {
+				log.Info("dry-run: would prune orphaned resource", "Resource", key)
+				p.recordReportEntry(ReconcileReportEntry{Resource: key, Action: ReconcileActionDelete})
+				continue
+			}
+			log.Info("pruning orphaned resource", "Resource", key)
+			if err := p.Client.Delete(ctx, &item, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+				log.Error(err, "error pruning orphaned resource", "Resource", key)
+				allErrors = append(allErrors, err)
+				continue
+			}
+			if p.recorder != nil {
+				p.recorder.Eventf(item.DeepCopyObject(), "Normal", "Pruned", "Pruned orphaned resource %s no longer present in rendered manifests", key)
+			}
+			prunedTotal.WithLabelValues(component, p.owner).Inc()
+		}
+	}
+	return utilerrors.NewAggregate(allErrors)
+}
@@ -3,35 +3,20 @@ package hacks
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
-	"time"
 
 	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
-	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/maistra/istio-operator/pkg/controller/common"
 )
 
-func WrapContext(ctx context.Context, earliestReconciliationTimes map[types.NamespacedName]time.Time) context.Context {
-	return context.WithValue(ctx, "earliestReconciliationTimes", earliestReconciliationTimes)
-}
-
-// SkipReconciliationUntilCacheSynced prevents the object from being reconciled in the next 2 seconds. Call this
-// function after you post an update to a resource if you want to reduce the likelihood of the reconcile() function
-// being called again before the update comes back into the operator (until it does, any invocation of reconcile() will
-// skip reconciliation and enqueue the object for reconciliation after the initial 2 second delay expires). This allows
-// the watch event more time to come back and update the cache.
-// While this 2s delay doesn't ensure that the cache is actually synced, it should improve 90% of cases.
-// For the complete explanation, see https://issues.jboss.org/projects/MAISTRA/issues/MAISTRA-830 and
-// https://issues.redhat.com/browse/MAISTRA-2047
-func SkipReconciliationUntilCacheSynced(ctx context.Context, namespacedName types.NamespacedName) {
-	// NOTE: storing earliestReconciliationTimes in ctx is wrong, but this is just a temporary hack
-	earliestReconciliationTimes, ok := ctx.Value("earliestReconciliationTimes").(map[types.NamespacedName]time.Time)
-	if !ok {
-		panic("No earliestReconciliationTimes map in context; you must invoke hacks.WrapContext() before invoking hacks.SkipReconciliationUntilCacheSynced()")
-	}
-	earliestReconciliationTimes[namespacedName] = time.Now().Add(2 * time.Second)
-}
+// NOTE: the context-smuggled earliestReconciliationTimes hack that used to
+// live here (WrapContext/SkipReconciliationUntilCacheSynced) has been
+// replaced by common.RecordWrite/common.WaitForCacheSync, which wait on the
+// controller-runtime cache actually catching up to a write instead of
+// sleeping a fixed 2 seconds and hoping. Reconcilers should call those
+// directly instead.
 
 // RemoveTypeObjectFieldsFromCRDSchema works around the problem where OpenShift 3.11 doesn't like "type: object"
 // in CRD OpenAPI schemas. This function removes all occurrences from the schema.
@@ -52,6 +37,29 @@ func IsTypeObjectProblemInCRDSchemas(err error) bool {
 	return err != nil && strings.Contains(err.Error(), "must only have \"properties\", \"required\" or \"description\" at the root if the status subresource is enabled")
 }
 
+// structuralSchemaFieldPattern extracts the offending schema path from the API
+// server's "must be structural" rejection, e.g.
+// "spec.versions[0].schema.openAPIV3Schema.properties[foo]: Forbidden: must be structural".
+var structuralSchemaFieldPattern = regexp.MustCompile(`spec\.versions\[\d+\]\.schema\.openAPIV3Schema[.\[\]\w]*`)
+
+// StructuralSchemaProblemField returns the schema field path and true if err is
+// the error the API server returns when a CRD's OpenAPI schema isn't structural
+// (e.g. uses oneOf/anyOf at a level that requires a type, or is missing a type
+// the status subresource needs), so callers can log exactly what needs fixing
+// instead of blindly retrying.
+func StructuralSchemaProblemField(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+	if !strings.Contains(err.Error(), "must be structural") {
+		return "", false
+	}
+	if field := structuralSchemaFieldPattern.FindString(err.Error()); field != "" {
+		return field, true
+	}
+	return "spec.versions[].schema.openAPIV3Schema", true
+}
+
 func removeTypeObjectField(schema *apiextensionsv1beta1.JSONSchemaProps) {
 	if schema == nil {
 		return
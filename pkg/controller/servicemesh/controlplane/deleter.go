@@ -1,18 +1,369 @@
 package controlplane
 
 import (
+	"context"
 	"fmt"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/maistra/istio-operator/pkg/apis/maistra/v1"
+	"github.com/maistra/istio-operator/pkg/controller/common"
 )
 
+// purgeDeletionPolicy is the only spec.deletionPolicy value that lets
+// runTeardownPhases remove the mesh's CRDs. Any other value (including unset)
+// leaves them on the cluster, since deleting a CRD deletes every custom
+// resource of its kind cluster-wide, not just this mesh's.
+const purgeDeletionPolicy = "Purge"
+
+// defaultTeardownPhaseTimeout bounds how long runTeardownPhases waits for
+// gateways to scale down or sidecars to evict before moving on to the next
+// phase, when spec.teardown.timeout isn't set.
+const defaultTeardownPhaseTimeout = 2 * time.Minute
+
+// deletionInProgressConditionType is the status condition Delete sets before
+// runTeardownPhases starts removing the mesh's resources, and clears once
+// runTeardownPhases returns. It's patched as unstructured JSON rather than through a typed
+// Status().Update, since the real ServiceMeshControlPlane status API
+// (pkg/apis/maistra/v1) isn't part of this checkout.
+const deletionInProgressConditionType = "DeletionInProgress"
+
+// componentInstallOrder lists control plane components in (roughly) the
+// order the chart installs them: the CNI plugin first, then security, galley,
+// pilot and the sidecar injector, then the gateways, mixer and telemetry
+// addons that depend on them. runTeardownPhases tears
+// components down in the reverse of this order, so nothing is pruned out
+// from under a component still depending on it.
+var componentInstallOrder = []string{
+	"istio_cni",
+	"security",
+	"galley",
+	"pilot",
+	"sidecarInjectorWebhook",
+	"ingressgateways",
+	"egressgateways",
+	"mixer",
+	"tracing",
+	"kiali",
+	"grafana",
+	"prometheus",
+}
+
+// gatewayComponents are the componentInstallOrder entries that render
+// Deployments fronting the data plane. runTeardownPhases scales these to 0
+// and waits for their pods to terminate before pruning anything else, so
+// in-flight traffic has a chance to drain instead of being cut off by a
+// gateway Pod disappearing mid-connection.
+var gatewayComponents = map[string]bool{
+	"ingressgateways": true,
+	"egressgateways":  true,
+}
+
 func (r *ControlPlaneReconciler) Delete() error {
+	if r.Instance.Spec.PreserveResourcesOnDeletion {
+		r.Manager.GetRecorder(controllerName).Event(r.Instance, "Normal", "ServiceMeshDeleting",
+			"preserveResourcesOnDeletion is set; leaving rendered resources in the cluster and only removing the finalizer")
+		return r.removeFinalizerOnly()
+	}
+
 	r.Manager.GetRecorder(controllerName).Event(r.Instance, "Normal", "ServiceMeshDeleting", "Deleting service mesh")
-	err := r.prune(-1)
-	defer func() {
-		if err == nil {
-			r.Manager.GetRecorder(controllerName).Event(r.Instance, "Normal", "ServiceMeshDeleted", "Successfully deleted service mesh components")
-		} else {
-			r.Manager.GetRecorder(controllerName).Event(r.Instance, "Warning", "ServiceMeshDeleted", fmt.Sprintf("Error occurred during service mesh deletion: %s", err))
-		}
-	}()
+	if err := r.setDeletionInProgressCondition(context.TODO(), true, "TeardownStarted", "Tearing down service mesh components"); err != nil {
+		r.Manager.GetRecorder(controllerName).Eventf(r.Instance, "Warning", "ServiceMeshDeleting", "error setting %s condition: %s", deletionInProgressConditionType, err)
+	}
+
+	err := r.runTeardownPhases()
+
+	if condErr := r.setDeletionInProgressCondition(context.TODO(), false, "TeardownComplete", "Finished tearing down service mesh components"); condErr != nil {
+		r.Manager.GetRecorder(controllerName).Eventf(r.Instance, "Warning", "ServiceMeshDeleting", "error clearing %s condition: %s", deletionInProgressConditionType, condErr)
+	}
+
+	if err == nil {
+		r.Manager.GetRecorder(controllerName).Event(r.Instance, "Normal", "ServiceMeshDeleted", "Successfully deleted service mesh components")
+	} else {
+		r.Manager.GetRecorder(controllerName).Event(r.Instance, "Warning", "ServiceMeshDeleted", fmt.Sprintf("Error occurred during service mesh deletion: %s", err))
+	}
 	return err
 }
+
+// runTeardownPhases drains the data plane's gateways, evicts sidecar-injected
+// pods (respecting PodDisruptionBudgets via the eviction API), then prunes
+// every control plane component in the reverse of componentInstallOrder, and
+// finally removes the mesh's CRDs if spec.deletionPolicy asks for that. Each
+// phase is bounded by spec.teardown.timeout (or defaultTeardownPhaseTimeout):
+// a phase that can't finish in time stops waiting and teardown moves on, rather
+// than leaving the ServiceMeshControlPlane stuck deleting forever.
+func (r *ControlPlaneReconciler) runTeardownPhases() error {
+	logger := common.LogFromContext(context.TODO())
+	timeout := r.teardownPhaseTimeout()
+
+	r.Manager.GetRecorder(controllerName).Eventf(r.Instance, "Normal", "ServiceMeshDeleting", "%s", teardownPhaseMessage(teardownPhaseDataPlane))
+	if err := r.drainGateways(context.TODO(), timeout); err != nil {
+		logger.Error(err, "error draining data plane gateways; continuing with teardown")
+	}
+
+	r.Manager.GetRecorder(controllerName).Eventf(r.Instance, "Normal", "ServiceMeshDeleting", "%s", teardownPhaseMessage(teardownPhaseSidecars))
+	if err := r.evictSidecars(context.TODO(), timeout); err != nil {
+		logger.Error(err, "error evicting sidecars; continuing with teardown")
+	}
+
+	r.Manager.GetRecorder(controllerName).Eventf(r.Instance, "Normal", "ServiceMeshDeleting", "%s", teardownPhaseMessage(teardownPhaseControlPlane))
+	for i := len(componentInstallOrder) - 1; i >= 0; i-- {
+		component := componentInstallOrder[i]
+		if err := r.pruneComponent(context.TODO(), component); err != nil {
+			return fmt.Errorf("error pruning component %s: %v", component, err)
+		}
+	}
+
+	if r.Instance.Spec.DeletionPolicy != purgeDeletionPolicy {
+		logger.Info("spec.deletionPolicy is not Purge; leaving mesh CRDs in place", "DeletionPolicy", r.Instance.Spec.DeletionPolicy)
+		return nil
+	}
+	return r.purgeCRDs(context.TODO())
+}
+
+// teardownPhaseMessage returns the event message announcing phase.
+func teardownPhaseMessage(phase teardownPhase) string {
+	switch phase {
+	case teardownPhaseDataPlane:
+		return "Tearing down: draining data plane gateways"
+	case teardownPhaseSidecars:
+		return "Tearing down: evicting sidecars"
+	case teardownPhaseControlPlane:
+		return "Tearing down: pruning control plane components"
+	default:
+		return string(phase)
+	}
+}
+
+// teardownPhaseTimeout returns spec.teardown.timeout, if the ServiceMeshControlPlane
+// sets one, or defaultTeardownPhaseTimeout otherwise.
+func (r *ControlPlaneReconciler) teardownPhaseTimeout() time.Duration {
+	if r.Instance.Spec.Teardown == nil || r.Instance.Spec.Teardown.Timeout == nil {
+		return defaultTeardownPhaseTimeout
+	}
+	return r.Instance.Spec.Teardown.Timeout.Duration
+}
+
+// drainGateways scales every gateway Deployment (ingressgateways and
+// egressgateways) owned by this mesh to 0 replicas, then polls until their
+// pods are gone or timeout elapses, so in-flight connections get a chance to
+// finish instead of being cut off when the Deployment is pruned outright.
+func (r *ControlPlaneReconciler) drainGateways(ctx context.Context, timeout time.Duration) error {
+	logger := common.LogFromContext(ctx)
+
+	for component := range gatewayComponents {
+		labelSelector := map[string]string{
+			common.OwnerKey:                  r.Instance.GetNamespace(),
+			common.KubernetesAppComponentKey: component,
+		}
+		deployments := &unstructured.UnstructuredList{}
+		deployments.SetGroupVersionKind(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DeploymentList"})
+		if err := r.Client.List(ctx, client.MatchingLabels(labelSelector).InNamespace(r.Instance.GetNamespace()), deployments); err != nil {
+			return err
+		}
+		for i := range deployments.Items {
+			deployment := &deployments.Items[i]
+			logger.Info("draining gateway deployment", "Component", component, "Deployment", deployment.GetName())
+			if err := unstructured.SetNestedField(deployment.Object, int64(0), "spec", "replicas"); err != nil {
+				return err
+			}
+			if err := r.Client.Update(ctx, deployment); err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.waitForGatewayPodsGone(ctx, timeout)
+}
+
+// waitForGatewayPodsGone polls until no gateway pods remain for this mesh or
+// timeout elapses, whichever comes first. Returning after the deadline
+// (rather than failing) is deliberate: a gateway pod that won't terminate
+// shouldn't block the rest of teardown indefinitely.
+func (r *ControlPlaneReconciler) waitForGatewayPodsGone(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pods := &core.PodList{}
+		labelSelector := map[string]string{common.OwnerKey: r.Instance.GetNamespace()}
+		if err := r.Client.List(ctx, client.MatchingLabels(labelSelector).InNamespace(r.Instance.GetNamespace()), pods); err != nil {
+			return err
+		}
+		remaining := 0
+		for _, pod := range pods.Items {
+			if gatewayComponents[pod.Labels[common.KubernetesAppComponentKey]] {
+				remaining++
+			}
+		}
+		if remaining == 0 || time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// evictSidecars evicts every pod carrying an istio-proxy sidecar in the
+// mesh's member namespaces via the policy Eviction API, instead of deleting
+// them directly, so a PodDisruptionBudget protecting the workload can reject
+// the eviction the same way it would for a node drain. A pod whose eviction
+// is refused (or that otherwise fails) is logged and left for its owning
+// workload to clean up; it's not bounced off the mesh by a raw deletion here.
+func (r *ControlPlaneReconciler) evictSidecars(ctx context.Context, timeout time.Duration) error {
+	logger := common.LogFromContext(ctx)
+
+	namespaces := &core.NamespaceList{}
+	labelSelector := map[string]string{common.MemberOfKey: r.Instance.GetNamespace()}
+	if err := r.Client.List(ctx, client.MatchingLabels(labelSelector), namespaces); err != nil {
+		return err
+	}
+	pods := &core.PodList{}
+	for _, ns := range namespaces.Items {
+		nsPods := &core.PodList{}
+		if err := r.Client.List(ctx, &client.ListOptions{Namespace: ns.GetName()}, nsPods); err != nil {
+			return err
+		}
+		pods.Items = append(pods.Items, nsPods.Items...)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for i := range pods.Items {
+		if time.Now().After(deadline) {
+			logger.Info("sidecar eviction phase timed out; leaving remaining pods for the next reconcile")
+			break
+		}
+		pod := &pods.Items[i]
+		if !hasIstioProxyContainer(pod) {
+			continue
+		}
+		eviction := &policy.Eviction{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "policy/v1beta1", Kind: "Eviction"},
+			ObjectMeta: metav1.ObjectMeta{Name: pod.GetName(), Namespace: pod.GetNamespace()},
+		}
+		if err := r.Client.Create(ctx, eviction); err != nil {
+			logger.Info("could not evict sidecar-injected pod; leaving it in place", "Namespace", pod.GetNamespace(), "Pod", pod.GetName(), "error", err.Error())
+		}
+	}
+	return nil
+}
+
+// hasIstioProxyContainer reports whether pod was injected with Istio's
+// sidecar proxy, i.e. whether evictSidecars should touch it at all.
+func hasIstioProxyContainer(pod *core.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == "istio-proxy" {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneComponent removes every resource labeled as belonging to component for
+// this mesh, the same way a normal reconcile prunes resources a chart no
+// longer renders - just with every resource orphaned instead of only the ones
+// missing from a new rendering, since component isn't being reinstalled.
+func (r *ControlPlaneReconciler) pruneComponent(ctx context.Context, component string) error {
+	noop := func(ctx context.Context, obj *unstructured.Unstructured) error { return nil }
+	processor := common.NewManifestProcessor(
+		common.ControllerResources{Client: r.Client, Log: common.LogFromContext(ctx)},
+		r.Instance.GetName(), "", r.Instance.GetNamespace(), noop, noop)
+	return processor.Prune(ctx, component, map[v1.ResourceKey]struct{}{})
+}
+
+// purgeCRDs deletes every CustomResourceDefinition owned by this mesh
+// (maistra.io/owner=<mesh namespace>), called only once spec.deletionPolicy
+// is Purge - see runTeardownPhases. Deleting a CRD removes every custom
+// resource of its kind cluster-wide, not just this mesh's, which is why it's
+// opt-in rather than part of the ordinary teardown phases above.
+func (r *ControlPlaneReconciler) purgeCRDs(ctx context.Context) error {
+	logger := common.LogFromContext(ctx)
+
+	crds := &unstructured.UnstructuredList{}
+	crds.SetGroupVersionKind(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinitionList"})
+	labelSelector := map[string]string{common.OwnerKey: r.Instance.GetNamespace()}
+	if err := r.Client.List(ctx, client.MatchingLabels(labelSelector), crds); err != nil {
+		return err
+	}
+	for i := range crds.Items {
+		crd := &crds.Items[i]
+		logger.Info("spec.deletionPolicy is Purge; deleting CRD", "CRD", crd.GetName())
+		if err := r.Client.Delete(ctx, crd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setDeletionInProgressCondition patches r.Instance's status.conditions,
+// setting (or clearing) deletionInProgressConditionType. This works against
+// the ServiceMeshControlPlane as unstructured JSON rather than through a
+// typed Status().Update, since the real status API
+// (pkg/apis/maistra/v1.ServiceMeshControlPlaneStatus) isn't part of this
+// checkout.
+func (r *ControlPlaneReconciler) setDeletionInProgressCondition(ctx context.Context, inProgress bool, reason, message string) error {
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(r.Instance.GetObjectKind().GroupVersionKind())
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: r.Instance.GetNamespace(), Name: r.Instance.GetName()}, current); err != nil {
+		return err
+	}
+
+	conditions, _, err := unstructured.NestedSlice(current.Object, "status", "conditions")
+	if err != nil {
+		return err
+	}
+	conditionStatus := "False"
+	if inProgress {
+		conditionStatus = "True"
+	}
+	updated := make([]interface{}, 0, len(conditions)+1)
+	for _, c := range conditions {
+		if m, ok := c.(map[string]interface{}); ok {
+			if name, _ := m["type"].(string); name == deletionInProgressConditionType {
+				continue
+			}
+		}
+		updated = append(updated, c)
+	}
+	updated = append(updated, map[string]interface{}{
+		"type":               deletionInProgressConditionType,
+		"status":             conditionStatus,
+		"reason":             reason,
+		"message":            message,
+		"lastTransitionTime": metav1.Now().UTC().Format(time.RFC3339),
+	})
+
+	if err := unstructured.SetNestedSlice(current.Object, updated, "status", "conditions"); err != nil {
+		return err
+	}
+	return r.Client.Status().Update(ctx, current)
+}
+
+// teardownPhase names one step of the ordered teardown sequence performed by
+// runTeardownPhases: draining the data plane's gateways, evicting sidecars,
+// then pruning the control plane itself in reverse install order.
+type teardownPhase string
+
+const (
+	teardownPhaseDataPlane    teardownPhase = "DataPlaneDraining"
+	teardownPhaseSidecars     teardownPhase = "SidecarsEvicting"
+	teardownPhaseControlPlane teardownPhase = "ControlPlanePruning"
+)
+
+// removeFinalizerOnly drops our finalizer from the ControlPlane without deleting
+// any of its rendered manifests, used when spec.preserveResourcesOnDeletion asks
+// us to leave the mesh's resources behind (e.g. so they can be adopted by a
+// replacement ControlPlane instead of being torn down and recreated).
+func (r *ControlPlaneReconciler) removeFinalizerOnly() error {
+	finalizers := r.Instance.ObjectMeta.Finalizers[:0:0]
+	for _, f := range r.Instance.ObjectMeta.Finalizers {
+		if f != finalizerName {
+			finalizers = append(finalizers, f)
+		}
+	}
+	r.Instance.ObjectMeta.Finalizers = finalizers
+	return r.Client.Update(context.TODO(), r.Instance)
+}
@@ -0,0 +1,128 @@
+package controlplane
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/maistra/istio-operator/pkg/controller/common"
+)
+
+// RemoteClusterKubeconfigLabel marks a Secret in the operator's namespace as
+// holding the kubeconfig for a remote cluster that should be considered for
+// multi-cluster ServiceMeshControlPlane federation. The secret's "name" key
+// (or, if absent, the secret's own name) is used as the cluster name.
+const RemoteClusterKubeconfigLabel = "istio/multiCluster"
+
+// RemoteClusterRegistry discovers remote clusters from kubeconfig Secrets
+// labeled with RemoteClusterKubeconfigLabel=true and lazily builds a
+// controller-runtime client for each one.
+//
+// This is the cluster-discovery-and-client-caching building block only; it
+// does not register informers/watches on those Secrets, does not reconcile
+// any ServiceMeshControlPlane in "primary" or "remote" mode, does not
+// provision east-west gateways, shared root CA Secrets, or remote-secret
+// installation, and isn't wired into main.go's flags or common.Config. A
+// caller wanting live re-sync on kubeconfig rotation must poll
+// DiscoverClusters and call Forget itself; there's no push notification yet.
+type RemoteClusterRegistry struct {
+	common.ControllerResources
+	operatorNamespace string
+
+	mu      sync.Mutex
+	clients map[string]client.Client
+}
+
+// NewRemoteClusterRegistry creates a registry that discovers kubeconfig Secrets
+// in operatorNamespace.
+func NewRemoteClusterRegistry(controllerResources common.ControllerResources, operatorNamespace string) *RemoteClusterRegistry {
+	return &RemoteClusterRegistry{
+		ControllerResources: controllerResources,
+		operatorNamespace:   operatorNamespace,
+		clients:             map[string]client.Client{},
+	}
+}
+
+// DiscoverClusters lists the kubeconfig Secrets currently present and returns
+// the set of cluster names they advertise.
+func (r *RemoteClusterRegistry) DiscoverClusters(ctx context.Context) ([]string, error) {
+	secrets := &core.SecretList{}
+	labelSelector := map[string]string{RemoteClusterKubeconfigLabel: "true"}
+	if err := r.Client.List(ctx, client.MatchingLabels(labelSelector).InNamespace(r.operatorNamespace), secrets); err != nil {
+		return nil, fmt.Errorf("error listing remote cluster kubeconfig secrets: %v", err)
+	}
+
+	clusters := make([]string, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		clusters = append(clusters, clusterNameFor(&secret))
+	}
+	return clusters, nil
+}
+
+// GetClient returns a client for the named remote cluster, building and caching
+// one from its kubeconfig Secret on first use.
+func (r *RemoteClusterRegistry) GetClient(ctx context.Context, clusterName string) (client.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cl, ok := r.clients[clusterName]; ok {
+		return cl, nil
+	}
+
+	secrets := &core.SecretList{}
+	labelSelector := map[string]string{RemoteClusterKubeconfigLabel: "true"}
+	if err := r.Client.List(ctx, client.MatchingLabels(labelSelector).InNamespace(r.operatorNamespace), secrets); err != nil {
+		return nil, fmt.Errorf("error listing remote cluster kubeconfig secrets: %v", err)
+	}
+
+	for _, secret := range secrets.Items {
+		if clusterNameFor(&secret) != clusterName {
+			continue
+		}
+		cl, err := clientForKubeconfigSecret(&secret)
+		if err != nil {
+			return nil, fmt.Errorf("error building client for cluster %s: %v", clusterName, err)
+		}
+		r.clients[clusterName] = cl
+		return cl, nil
+	}
+	return nil, fmt.Errorf("no kubeconfig secret found for cluster %s in namespace %s", clusterName, r.operatorNamespace)
+}
+
+// Forget drops a cached client, forcing it to be rebuilt from its Secret the
+// next time it's requested. Call this after a kubeconfig Secret is updated.
+func (r *RemoteClusterRegistry) Forget(clusterName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, clusterName)
+}
+
+func clusterNameFor(secret *core.Secret) string {
+	if name, ok := secret.Data["name"]; ok && len(name) > 0 {
+		return string(name)
+	}
+	return secret.GetName()
+}
+
+func clientForKubeconfigSecret(secret *core.Secret) (client.Client, error) {
+	kubeconfig, ok := secret.Data["kubeconfig"]
+	if !ok {
+		return nil, fmt.Errorf("secret %s has no kubeconfig key", secret.GetName())
+	}
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return newClusterClient(restConfig)
+}
+
+// newClusterClient is split out from clientForKubeconfigSecret so tests can
+// stub it out instead of dialing a real apiserver.
+var newClusterClient = func(restConfig *rest.Config) (client.Client, error) {
+	return client.New(restConfig, client.Options{})
+}
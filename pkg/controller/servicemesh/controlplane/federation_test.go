@@ -0,0 +1,113 @@
+package controlplane
+
+import (
+	"context"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/maistra/istio-operator/pkg/controller/common"
+	"github.com/maistra/istio-operator/pkg/controller/common/test"
+)
+
+const testOperatorNamespace = "istio-operator"
+
+func newKubeconfigSecret(name, clusterName string) *core.Secret {
+	return &core.Secret{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: testOperatorNamespace,
+			Labels:    map[string]string{RemoteClusterKubeconfigLabel: "true"},
+		},
+		Data: map[string][]byte{
+			"name":       []byte(clusterName),
+			"kubeconfig": []byte("not-used-because-newClusterClient-is-stubbed"),
+		},
+	}
+}
+
+func withStubbedClusterClient(t *testing.T, stub client.Client) {
+	t.Helper()
+	original := newClusterClient
+	newClusterClient = func(restConfig *rest.Config) (client.Client, error) {
+		return stub, nil
+	}
+	t.Cleanup(func() { newClusterClient = original })
+}
+
+func TestDiscoverClustersReturnsClusterNamesOfLabeledSecrets(t *testing.T) {
+	remote := newKubeconfigSecret("remote-1-kubeconfig", "remote-1")
+	other := &core.Secret{ObjectMeta: meta.ObjectMeta{Name: "unrelated", Namespace: testOperatorNamespace}}
+	cl, _ := test.CreateClient(remote, other)
+
+	registry := NewRemoteClusterRegistry(common.ControllerResources{Client: cl}, testOperatorNamespace)
+	clusters, err := registry.DiscoverClusters(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(clusters) != 1 || clusters[0] != "remote-1" {
+		t.Errorf("expected [remote-1], got %v", clusters)
+	}
+}
+
+func TestGetClientCachesBuiltClient(t *testing.T) {
+	remote := newKubeconfigSecret("remote-1-kubeconfig", "remote-1")
+	cl, _ := test.CreateClient(remote)
+	stub, _ := test.CreateClient()
+	withStubbedClusterClient(t, stub)
+
+	registry := NewRemoteClusterRegistry(common.ControllerResources{Client: cl}, testOperatorNamespace)
+	first, err := registry.GetClient(context.TODO(), "remote-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != stub {
+		t.Fatal("expected the client built by newClusterClient to be returned")
+	}
+
+	second, err := registry.GetClient(context.TODO(), "remote-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second != first {
+		t.Error("expected GetClient to return the cached client on the second call")
+	}
+}
+
+func TestGetClientErrorsWhenSecretMissing(t *testing.T) {
+	cl, _ := test.CreateClient()
+	registry := NewRemoteClusterRegistry(common.ControllerResources{Client: cl}, testOperatorNamespace)
+
+	if _, err := registry.GetClient(context.TODO(), "no-such-cluster"); err == nil {
+		t.Error("expected an error when no kubeconfig secret exists for the cluster")
+	}
+}
+
+func TestForgetDropsCachedClient(t *testing.T) {
+	remote := newKubeconfigSecret("remote-1-kubeconfig", "remote-1")
+	cl, _ := test.CreateClient(remote)
+	firstStub, _ := test.CreateClient()
+	withStubbedClusterClient(t, firstStub)
+
+	registry := NewRemoteClusterRegistry(common.ControllerResources{Client: cl}, testOperatorNamespace)
+	if _, err := registry.GetClient(context.TODO(), "remote-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	secondStub, _ := test.CreateClient()
+	newClusterClient = func(restConfig *rest.Config) (client.Client, error) {
+		return secondStub, nil
+	}
+
+	registry.Forget("remote-1")
+	rebuilt, err := registry.GetClient(context.TODO(), "remote-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rebuilt != secondStub {
+		t.Error("expected Forget to force GetClient to rebuild the client")
+	}
+}
@@ -0,0 +1,276 @@
+package memberroll
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	core "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/maistra/istio-operator/pkg/controller/common"
+)
+
+// netAttachDefName is the name given to the NetworkAttachmentDefinition that
+// reconcileNamespaceInMesh creates in every member namespace, so the Istio
+// CNI plugin picks up pods created there.
+const netAttachDefName = "istio-cni"
+
+// netAttachDefGVK is the GVK of Multus's NetworkAttachmentDefinition CRD.
+// It's handled as unstructured, since the k8snetworkplumbingwg API isn't
+// vendored into this operator.
+var netAttachDefGVK = schema.GroupVersionKind{
+	Group:   "k8s.cni.cncf.io",
+	Version: "v1",
+	Kind:    "NetworkAttachmentDefinition",
+}
+
+// NamespaceReconciler reconciles a single namespace's membership in (or
+// removal from) a mesh. namespaceReconciler is the top-level implementation,
+// covering the namespace's member-of label, NetworkAttachmentDefinition and
+// RoleBindings; it delegates to an installed networkingStrategy (e.g.
+// networkPolicyStrategy) for anything networking-policy-specific. The two
+// implementations share the interface so tests can install a fake
+// networkingStrategy without depending on NetworkPolicy at all.
+type NamespaceReconciler interface {
+	reconcileNamespaceInMesh(namespace string) error
+	removeNamespaceFromMesh(namespace string) error
+}
+
+// namespaceReconciler is the default NamespaceReconciler. It creates the CNI
+// NetworkAttachmentDefinition in every member namespace and mirrors the
+// RoleBindings labeled with common.OwnerKey=meshNamespace into it.
+type namespaceReconciler struct {
+	common.ControllerResources
+	meshNamespace              string
+	preserveResourcesOnRemoval bool
+	networkingStrategy         NamespaceReconciler
+}
+
+// newNamespaceReconciler builds the NamespaceReconciler for meshNamespace.
+// When preserveResourcesOnRemoval is set, removeNamespaceFromMesh leaves a
+// member namespace's NetworkAttachmentDefinition and RoleBindings in place
+// instead of deleting them, and passes the same flag through to
+// networkingStrategy so NetworkPolicies are preserved too. This backs
+// spec.preserveResourcesOnDeletion on the ServiceMeshMemberRoll.
+//
+// excludedNamespaces is passed straight through to networkingStrategy (via
+// newNetworkPolicyStrategyWithExclusions), so namespaces named in it never
+// have NetworkPolicies reconciled into or removed from them, even if they're
+// later added to (or already part of) the MemberRoll.
+func newNamespaceReconciler(cl client.Client, baseLogger logr.Logger, meshNamespace string, preserveResourcesOnRemoval bool, excludedNamespaces []string) (NamespaceReconciler, error) {
+	networkingStrategy, err := newNetworkPolicyStrategyWithExclusions(cl, baseLogger, meshNamespace, preserveResourcesOnRemoval, excludedNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	return &namespaceReconciler{
+		ControllerResources: common.ControllerResources{
+			Client: cl,
+			Log:    baseLogger.WithValues("Component", "namespace"),
+		},
+		meshNamespace:              meshNamespace,
+		preserveResourcesOnRemoval: preserveResourcesOnRemoval,
+		networkingStrategy:         networkingStrategy,
+	}, nil
+}
+
+func (r *namespaceReconciler) reconcileNamespaceInMesh(namespace string) error {
+	logger := r.Log.WithValues("Namespace", namespace)
+
+	ns := &core.Namespace{}
+	if err := r.Client.Get(context.TODO(), client.ObjectKey{Name: namespace}, ns); err != nil {
+		logger.Error(err, "error retrieving namespace")
+		return err
+	}
+	if existingMesh, ok := ns.Labels[common.MemberOfKey]; ok && existingMesh != r.meshNamespace {
+		return fmt.Errorf("namespace %s is already a member of mesh %s", namespace, existingMesh)
+	}
+	if ns.Labels[common.MemberOfKey] != r.meshNamespace {
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+		ns.Labels[common.MemberOfKey] = r.meshNamespace
+		if err := r.Client.Update(context.TODO(), ns); err != nil {
+			logger.Error(err, "error labeling namespace as a mesh member")
+			return err
+		}
+	}
+
+	if err := r.reconcileNetAttachDef(namespace); err != nil {
+		logger.Error(err, "error reconciling NetworkAttachmentDefinition")
+		return err
+	}
+
+	if err := r.reconcileRoleBindings(namespace); err != nil {
+		logger.Error(err, "error reconciling RoleBindings")
+		return err
+	}
+
+	return r.networkingStrategy.reconcileNamespaceInMesh(namespace)
+}
+
+// reconcileNetAttachDef creates namespace's NetworkAttachmentDefinition if it
+// doesn't already exist. There's nothing to drift-detect: the object has no
+// mesh-specific contents beyond its name and member-of label.
+func (r *namespaceReconciler) reconcileNetAttachDef(namespace string) error {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(netAttachDefGVK)
+	err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: netAttachDefName}, existing)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	netAttachDef := &unstructured.Unstructured{}
+	netAttachDef.SetGroupVersionKind(netAttachDefGVK)
+	netAttachDef.SetNamespace(namespace)
+	netAttachDef.SetName(netAttachDefName)
+	common.SetLabel(netAttachDef, common.MemberOfKey, r.meshNamespace)
+	return r.Client.Create(context.TODO(), netAttachDef)
+}
+
+// reconcileRoleBindings mirrors every RoleBinding labeled
+// common.OwnerKey=meshNamespace from the mesh namespace into namespace,
+// deleting any of namespace's previously-mirrored copies that no longer have
+// a source RoleBinding in the mesh namespace.
+func (r *namespaceReconciler) reconcileRoleBindings(namespace string) error {
+	meshRoleBindings := &rbac.RoleBindingList{}
+	meshSelector := map[string]string{common.OwnerKey: r.meshNamespace}
+	if err := r.Client.List(context.TODO(), client.MatchingLabels(meshSelector).InNamespace(r.meshNamespace), meshRoleBindings); err != nil {
+		return err
+	}
+
+	existingRoleBindings := &rbac.RoleBindingList{}
+	memberSelector := map[string]string{common.MemberOfKey: r.meshNamespace}
+	if err := r.Client.List(context.TODO(), client.MatchingLabels(memberSelector).InNamespace(namespace), existingRoleBindings); err != nil {
+		return err
+	}
+	existingNames := sets.NewString()
+	for _, rb := range existingRoleBindings.Items {
+		existingNames.Insert(rb.GetName())
+	}
+
+	requiredNames := sets.NewString()
+	var allErrors []error
+	for i := range meshRoleBindings.Items {
+		meshRB := &meshRoleBindings.Items[i]
+		requiredNames.Insert(meshRB.GetName())
+		if err := r.reconcileSingleRoleBinding(namespace, meshRB); err != nil {
+			allErrors = append(allErrors, err)
+		}
+	}
+
+	for _, obsoleteName := range existingNames.Difference(requiredNames).List() {
+		roleBinding := &rbac.RoleBinding{ObjectMeta: meta.ObjectMeta{Name: obsoleteName, Namespace: namespace}}
+		if err := r.Client.Delete(context.TODO(), roleBinding); err != nil && !(errors.IsNotFound(err) || errors.IsGone(err)) {
+			allErrors = append(allErrors, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(allErrors)
+}
+
+func (r *namespaceReconciler) reconcileSingleRoleBinding(namespace string, meshRB *rbac.RoleBinding) error {
+	namespaceRB := r.buildNamespaceRoleBinding(namespace, meshRB)
+
+	existing := &rbac.RoleBinding{}
+	err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: meshRB.GetName()}, existing)
+	if errors.IsNotFound(err) {
+		return r.Client.Create(context.TODO(), namespaceRB)
+	}
+	if err != nil {
+		return err
+	}
+	namespaceRB.ResourceVersion = existing.ResourceVersion
+	return r.Client.Update(context.TODO(), namespaceRB)
+}
+
+// buildNamespaceRoleBinding builds namespace's copy of meshRB: same RoleRef
+// and Subjects, but with its own ObjectMeta, labeled as belonging to
+// r.meshNamespace.
+func (r *namespaceReconciler) buildNamespaceRoleBinding(namespace string, meshRB *rbac.RoleBinding) *rbac.RoleBinding {
+	roleBinding := meshRB.DeepCopy()
+	roleBinding.ObjectMeta = meta.ObjectMeta{
+		Name:        meshRB.GetName(),
+		Namespace:   namespace,
+		Labels:      copyMap(meshRB.Labels),
+		Annotations: copyMap(meshRB.Annotations),
+	}
+	common.SetLabel(roleBinding, common.MemberOfKey, r.meshNamespace)
+	return roleBinding
+}
+
+func (r *namespaceReconciler) removeNamespaceFromMesh(namespace string) error {
+	logger := r.Log.WithValues("Namespace", namespace)
+
+	var allErrors []error
+
+	ns := &core.Namespace{}
+	if err := r.Client.Get(context.TODO(), client.ObjectKey{Name: namespace}, ns); err != nil {
+		if !errors.IsNotFound(err) {
+			allErrors = append(allErrors, err)
+		}
+	} else if _, ok := ns.Labels[common.MemberOfKey]; ok {
+		delete(ns.Labels, common.MemberOfKey)
+		if err := r.Client.Update(context.TODO(), ns); err != nil {
+			logger.Error(err, "error removing member-of label from namespace")
+			allErrors = append(allErrors, err)
+		}
+	}
+
+	if r.preserveResourcesOnRemoval {
+		logger.Info("preserveResourcesOnDeletion is set; leaving NetworkAttachmentDefinition and RoleBindings in namespace")
+	} else {
+		if err := r.deleteNetAttachDef(namespace); err != nil {
+			logger.Error(err, "error deleting NetworkAttachmentDefinition")
+			allErrors = append(allErrors, err)
+		}
+		if err := r.deleteRoleBindings(namespace); err != nil {
+			logger.Error(err, "error deleting RoleBindings")
+			allErrors = append(allErrors, err)
+		}
+	}
+
+	if err := r.networkingStrategy.removeNamespaceFromMesh(namespace); err != nil {
+		allErrors = append(allErrors, err)
+	}
+
+	return utilerrors.NewAggregate(allErrors)
+}
+
+func (r *namespaceReconciler) deleteNetAttachDef(namespace string) error {
+	netAttachDef := &unstructured.Unstructured{}
+	netAttachDef.SetGroupVersionKind(netAttachDefGVK)
+	netAttachDef.SetNamespace(namespace)
+	netAttachDef.SetName(netAttachDefName)
+	err := r.Client.Delete(context.TODO(), netAttachDef)
+	if err != nil && !(errors.IsNotFound(err) || errors.IsGone(err)) {
+		return err
+	}
+	return nil
+}
+
+func (r *namespaceReconciler) deleteRoleBindings(namespace string) error {
+	roleBindings := &rbac.RoleBindingList{}
+	memberSelector := map[string]string{common.MemberOfKey: r.meshNamespace}
+	if err := r.Client.List(context.TODO(), client.MatchingLabels(memberSelector).InNamespace(namespace), roleBindings); err != nil {
+		return err
+	}
+	var allErrors []error
+	for i := range roleBindings.Items {
+		roleBinding := &roleBindings.Items[i]
+		if err := r.Client.Delete(context.TODO(), roleBinding); err != nil && !(errors.IsNotFound(err) || errors.IsGone(err)) {
+			allErrors = append(allErrors, err)
+		}
+	}
+	return utilerrors.NewAggregate(allErrors)
+}
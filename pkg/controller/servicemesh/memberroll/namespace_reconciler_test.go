@@ -7,6 +7,7 @@ import (
 	core "k8s.io/api/core/v1"
 	rbac "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -19,6 +20,41 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
+const (
+	appNamespace          = "bookinfo"
+	controlPlaneNamespace = "istio-system"
+)
+
+func newAppNamespace() *core.Namespace {
+	return &core.Namespace{
+		ObjectMeta: meta.ObjectMeta{
+			Name: appNamespace,
+		},
+	}
+}
+
+func newMeshRoleBinding() *rbac.RoleBinding {
+	return &rbac.RoleBinding{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "user-access",
+			Namespace: controlPlaneNamespace,
+			Labels:    map[string]string{common.OwnerKey: controlPlaneNamespace},
+		},
+		RoleRef: rbac.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     "istio-mesh-user",
+		},
+		Subjects: []rbac.Subject{
+			{
+				Kind:      rbac.UserKind,
+				Name:      "bob",
+				Namespace: controlPlaneNamespace,
+			},
+		},
+	}
+}
+
 func TestReconcileNamespaceInMesh(t *testing.T) {
 	namespace := newAppNamespace()
 	meshRoleBinding := newMeshRoleBinding()
@@ -172,8 +208,71 @@ func TestReconcileDeletesObsoleteRoleBindings(t *testing.T) {
 	assert.DeepEquals(roleBindings.Items, []rbac.RoleBinding{}, "Unexpected RoleBindings found in namespace", t)
 }
 
+func TestRemoveNamespaceFromMeshPreservesNetAttachDefAndRoleBindingsWhenConfigured(t *testing.T) {
+	namespace := newAppNamespace()
+	meshRoleBinding := newMeshRoleBinding()
+	cl, _ := test.CreateClient(namespace, meshRoleBinding)
+	setupReconciledNamespace(t, cl, appNamespace)
+
+	reconciler, err := newNamespaceReconciler(cl, logf.Log, controlPlaneNamespace, true, nil)
+	if err != nil {
+		t.Fatalf("Error creating namespace reconciler: %v", err)
+	}
+	fakeNetworkStrategy := &fakeNetworkStrategy{}
+	(reconciler.(*namespaceReconciler)).networkingStrategy = fakeNetworkStrategy
+
+	if err := reconciler.removeNamespaceFromMesh(appNamespace); err != nil {
+		t.Fatalf("removeNamespaceFromMesh returned an error: %v", err)
+	}
+
+	// member-of label is still removed even when preserving resources: the
+	// namespace itself is leaving the mesh, only its rendered resources stay.
+	ns := &core.Namespace{}
+	test.GetObject(cl, types.NamespacedName{Name: appNamespace}, ns)
+	_, found := ns.Labels[common.MemberOfKey]
+	assert.False(found, "Expected member-of label to be removed even when preserving resources, but it is still present", t)
+
+	// check that net-attach-def was preserved
+	netAttachDef := &unstructured.Unstructured{}
+	netAttachDef.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "k8s.cni.cncf.io",
+		Version: "v1",
+		Kind:    "NetworkAttachmentDefinition",
+	})
+	err = cl.Get(context.TODO(), types.NamespacedName{Namespace: appNamespace, Name: netAttachDefName}, netAttachDef)
+	if err != nil {
+		t.Fatalf("Expected NetworkAttachmentDefinition to be preserved, got err: %v", err)
+	}
+
+	// check that role binding was preserved
+	roleBinding := &rbac.RoleBinding{}
+	err = cl.Get(context.TODO(), types.NamespacedName{Namespace: appNamespace, Name: meshRoleBinding.Name}, roleBinding)
+	if err != nil {
+		t.Fatalf("Expected RoleBinding to be preserved, got err: %v", err)
+	}
+
+	assert.DeepEquals(fakeNetworkStrategy.removedNamespaces, []string{appNamespace}, "Expected removeNamespaceFromMesh to still invoke the networkStrategy so it can apply its own preservation", t)
+}
+
+func TestNewNamespaceReconcilerThreadsExcludedNamespaces(t *testing.T) {
+	namespace := newAppNamespace()
+	meshRoleBinding := newMeshRoleBinding()
+	cl, _ := test.CreateClient(namespace, meshRoleBinding)
+
+	reconciler, err := newNamespaceReconciler(cl, logf.Log, controlPlaneNamespace, true, []string{appNamespace})
+	if err != nil {
+		t.Fatalf("Error creating namespace reconciler: %v", err)
+	}
+
+	strategy, ok := (reconciler.(*namespaceReconciler)).networkingStrategy.(*networkPolicyStrategy)
+	if !ok {
+		t.Fatal("Expected newNamespaceReconciler's networkingStrategy to be a *networkPolicyStrategy")
+	}
+	assert.True(strategy.excludedNamespaces.Has(appNamespace), "Expected excludedNamespaces passed to newNamespaceReconciler to reach its networkPolicyStrategy", t)
+}
+
 func setupReconciledNamespace(t *testing.T, cl client.Client, namespace string) {
-	reconciler, err := newNamespaceReconciler(cl, logf.Log, controlPlaneNamespace, true)
+	reconciler, err := newNamespaceReconciler(cl, logf.Log, controlPlaneNamespace, true, nil)
 	if err != nil {
 		t.Fatalf("Error creating namespace reconciler: %v", err)
 	}
@@ -192,7 +291,7 @@ func assertNotFound(err error, message string, t *testing.T) {
 }
 
 func assertReconcileNamespaceSucceeds(t *testing.T, cl client.Client, networkStrategy NamespaceReconciler) {
-	reconciler, err := newNamespaceReconciler(cl, logf.Log, controlPlaneNamespace, true)
+	reconciler, err := newNamespaceReconciler(cl, logf.Log, controlPlaneNamespace, true, nil)
 	if err != nil {
 		t.Fatalf("Error creating namespace reconciler: %v", err)
 	}
@@ -207,7 +306,7 @@ func assertReconcileNamespaceSucceeds(t *testing.T, cl client.Client, networkStr
 }
 
 func assertRemoveNamespaceSucceeds(t *testing.T, cl client.Client, networkStrategy NamespaceReconciler) {
-	reconciler, err := newNamespaceReconciler(cl, logf.Log, controlPlaneNamespace, true)
+	reconciler, err := newNamespaceReconciler(cl, logf.Log, controlPlaneNamespace, false, nil)
 	if err != nil {
 		t.Fatalf("Error creating namespace reconciler: %v", err)
 	}
@@ -222,7 +321,7 @@ func assertRemoveNamespaceSucceeds(t *testing.T, cl client.Client, networkStrate
 }
 
 func assertReconcileNamespaceFails(t *testing.T, cl client.Client) {
-	reconciler, err := newNamespaceReconciler(cl, logf.Log, controlPlaneNamespace, true)
+	reconciler, err := newNamespaceReconciler(cl, logf.Log, controlPlaneNamespace, true, nil)
 	if err != nil {
 		t.Fatalf("Error creating namespace reconciler: %v", err)
 	}
@@ -0,0 +1,130 @@
+package memberroll
+
+import (
+	"context"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/maistra/istio-operator/pkg/controller/common"
+)
+
+// networkPolicyMemberOfIndexField is the controller-runtime cache index on
+// NetworkPolicy's maistra.io/member-of label, keyed by the mesh namespace it
+// names. SweepOrphanNetworkPolicies (and anything else that needs "every
+// NetworkPolicy belonging to mesh X") uses it to fetch one mesh's policies
+// from the cache; finding which mesh namespaces are referenced at all still
+// takes one cluster-wide List (see distinctIndexedMeshNamespaces).
+const networkPolicyMemberOfIndexField = "networkpolicy.maistra.io/member-of"
+
+// serviceMeshControlPlaneListKind is the GVK used to check whether a mesh
+// namespace still hosts an SMCP. It's looked up as unstructured, since the
+// typed ServiceMeshControlPlane API isn't available to this package.
+var serviceMeshControlPlaneListKind = schema.GroupVersionKind{
+	Group:   "maistra.io",
+	Version: "v1",
+	Kind:    "ServiceMeshControlPlaneList",
+}
+
+// RegisterNetworkPolicyMemberOfIndexer adds the networkPolicyMemberOfIndexField
+// index to mgr's cache. Call it once during manager setup, before the cache
+// starts, and before calling SweepOrphanNetworkPolicies.
+func RegisterNetworkPolicyMemberOfIndexer(mgr manager.Manager) error {
+	return mgr.GetCache().IndexField(&networking.NetworkPolicy{}, networkPolicyMemberOfIndexField, func(obj runtime.Object) []string {
+		networkPolicy, ok := obj.(*networking.NetworkPolicy)
+		if !ok {
+			return nil
+		}
+		meshNamespace, ok := networkPolicy.GetLabels()[common.MemberOfKey]
+		if !ok {
+			return nil
+		}
+		return []string{meshNamespace}
+	})
+}
+
+// SweepOrphanNetworkPolicies lists every NetworkPolicy labeled
+// maistra.io/member-of, groups them by the mesh namespace they reference
+// (fetching each group via the networkPolicyMemberOfIndexField index), and
+// deletes the ones
+// whose mesh namespace no longer hosts an SMCP. It's meant to run once at
+// controller startup, after RegisterNetworkPolicyMemberOfIndexer's index has
+// synced, so a mesh deleted while the operator was down doesn't leave its
+// members' NetworkPolicy copies behind indefinitely.
+func SweepOrphanNetworkPolicies(ctx context.Context, cl client.Client) error {
+	logger := common.LogFromContext(ctx)
+
+	meshNamespaces, err := distinctIndexedMeshNamespaces(ctx, cl)
+	if err != nil {
+		return err
+	}
+
+	for _, meshNamespace := range meshNamespaces {
+		hasMesh, err := meshNamespaceHasSMCP(ctx, cl, meshNamespace)
+		if err != nil {
+			logger.Error(err, "error checking for SMCP while sweeping orphaned NetworkPolicies", "MeshNamespace", meshNamespace)
+			continue
+		}
+		if hasMesh {
+			continue
+		}
+		policies := &networking.NetworkPolicyList{}
+		if err := cl.List(ctx, client.MatchingField(networkPolicyMemberOfIndexField, meshNamespace), policies); err != nil {
+			logger.Error(err, "error listing NetworkPolicies for a deleted mesh's namespace", "MeshNamespace", meshNamespace)
+			continue
+		}
+		for _, np := range policies.Items {
+			np := np
+			// a client that ignores field selectors returns every policy
+			if np.GetLabels()[common.MemberOfKey] != meshNamespace {
+				continue
+			}
+			logger.Info("deleting orphaned NetworkPolicy belonging to a deleted mesh", "MeshNamespace", meshNamespace, "Namespace", np.GetNamespace(), "NetworkPolicy", np.GetName())
+			if err := cl.Delete(ctx, &np); err != nil && !(errors.IsNotFound(err) || errors.IsGone(err)) {
+				logger.Error(err, "error deleting orphaned NetworkPolicy", "Namespace", np.GetNamespace(), "NetworkPolicy", np.GetName())
+			}
+		}
+	}
+	return nil
+}
+
+// distinctIndexedMeshNamespaces returns the distinct set of mesh namespaces
+// referenced by any NetworkPolicy's maistra.io/member-of label. A field
+// indexer only supports exact-match lookups ("every NetworkPolicy with field
+// X == value"), not enumerating the distinct values it's seen, so finding
+// the candidate namespaces in the first place still requires one cluster-wide
+// List; SweepOrphanNetworkPolicies then uses networkPolicyMemberOfIndexField
+// via client.MatchingFields to fetch each deleted mesh's policies, instead of
+// filtering the same full list client-side a second time.
+func distinctIndexedMeshNamespaces(ctx context.Context, cl client.Client) ([]string, error) {
+	allNetworkPolicies := &networking.NetworkPolicyList{}
+	if err := cl.List(ctx, &client.ListOptions{}, allNetworkPolicies); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var meshNamespaces []string
+	for _, np := range allNetworkPolicies.Items {
+		meshNamespace, ok := np.GetLabels()[common.MemberOfKey]
+		if !ok || seen[meshNamespace] {
+			continue
+		}
+		seen[meshNamespace] = true
+		meshNamespaces = append(meshNamespaces, meshNamespace)
+	}
+	return meshNamespaces, nil
+}
+
+func meshNamespaceHasSMCP(ctx context.Context, cl client.Client, meshNamespace string) (bool, error) {
+	smcpList := &unstructured.UnstructuredList{}
+	smcpList.SetGroupVersionKind(serviceMeshControlPlaneListKind)
+	err := cl.List(ctx, client.InNamespace(meshNamespace), smcpList)
+	if err != nil {
+		return false, err
+	}
+	return len(smcpList.Items) > 0, nil
+}
@@ -0,0 +1,83 @@
+package memberroll
+
+import (
+	"context"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/maistra/istio-operator/pkg/controller/common"
+	"github.com/maistra/istio-operator/pkg/controller/common/test"
+)
+
+func newMemberNetworkPolicy(meshNamespace, memberNamespace, name string) *networking.NetworkPolicy {
+	return &networking.NetworkPolicy{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: memberNamespace,
+			Labels:    map[string]string{common.MemberOfKey: meshNamespace},
+		},
+	}
+}
+
+func newSMCP(namespace, name string) *unstructured.Unstructured {
+	smcp := &unstructured.Unstructured{}
+	smcp.SetGroupVersionKind(serviceMeshControlPlaneListKind.GroupVersion().WithKind("ServiceMeshControlPlane"))
+	smcp.SetNamespace(namespace)
+	smcp.SetName(name)
+	return smcp
+}
+
+func TestSweepOrphanNetworkPoliciesDeletesPoliciesForDeletedMesh(t *testing.T) {
+	orphan := newMemberNetworkPolicy("gone-mesh", "bookinfo", "allow-mesh")
+	cl, _ := test.CreateClient(orphan)
+
+	if err := SweepOrphanNetworkPolicies(context.TODO(), cl); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "bookinfo", Name: "allow-mesh"}, &networking.NetworkPolicy{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected orphaned NetworkPolicy to be deleted, got err=%v", err)
+	}
+}
+
+func TestSweepOrphanNetworkPoliciesKeepsPoliciesForLiveMesh(t *testing.T) {
+	kept := newMemberNetworkPolicy("istio-system", "bookinfo", "allow-mesh")
+	smcp := newSMCP("istio-system", "basic")
+	cl, _ := test.CreateClient(kept, smcp)
+
+	if err := SweepOrphanNetworkPolicies(context.TODO(), cl); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "bookinfo", Name: "allow-mesh"}, &networking.NetworkPolicy{})
+	if err != nil {
+		t.Errorf("expected NetworkPolicy for a live mesh to be kept, got err=%v", err)
+	}
+}
+
+func TestSweepOrphanNetworkPoliciesHandlesEachMeshIndependently(t *testing.T) {
+	orphan := newMemberNetworkPolicy("gone-mesh", "bookinfo", "allow-mesh")
+	kept := newMemberNetworkPolicy("istio-system", "bookinfo2", "allow-mesh")
+	smcp := newSMCP("istio-system", "basic")
+	cl, _ := test.CreateClient(orphan, kept, smcp)
+
+	if err := SweepOrphanNetworkPolicies(context.TODO(), cl); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "bookinfo", Name: "allow-mesh"}, &networking.NetworkPolicy{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected the orphaned mesh's NetworkPolicy to be deleted, got err=%v", err)
+	}
+
+	err = cl.Get(context.TODO(), types.NamespacedName{Namespace: "bookinfo2", Name: "allow-mesh"}, &networking.NetworkPolicy{})
+	if err != nil {
+		t.Errorf("expected the live mesh's NetworkPolicy to be kept, got err=%v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package memberroll
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// maxNetworkPolicyRetries bounds how many times networkPolicyRetryQueue
+// retries a single {namespace, policy} item after a propagation error before
+// giving up on it until the next full member-roll reconcile.
+const maxNetworkPolicyRetries = 5
+
+// networkPolicyRetryReason classifies why a {namespace, policy} item was
+// enqueued, for the networkPolicyRetriesTotal metric below.
+type networkPolicyRetryReason string
+
+const (
+	retryReasonCreateFailed networkPolicyRetryReason = "create-failed"
+	retryReasonUpdateFailed networkPolicyRetryReason = "update-failed"
+	retryReasonDeleteFailed networkPolicyRetryReason = "delete-failed"
+)
+
+var networkPolicyRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "maistra_memberroll_networkpolicy_retries_total",
+	Help: "Number of times a member namespace's NetworkPolicy was retried after a propagation error, labeled by namespace, policy and failure reason",
+}, []string{"namespace", "policy", "reason"})
+
+func init() {
+	metrics.Registry.MustRegister(networkPolicyRetriesTotal)
+}
+
+// networkPolicyRetryKey identifies a single member namespace's copy of a
+// required NetworkPolicy, the unit networkPolicyRetryQueue retries at.
+type networkPolicyRetryKey struct {
+	namespace  string
+	policyName string
+}
+
+// networkPolicyRetryQueue retries a single member namespace's NetworkPolicy
+// after a Create/Update/Delete failure, instead of relying on a full
+// member-roll reconcile to notice and retry it. Items back off exponentially
+// between attempts and are dropped after maxNetworkPolicyRetries, so a
+// permanently failing policy doesn't spin the worker forever.
+type networkPolicyRetryQueue struct {
+	queue    workqueue.RateLimitingInterface
+	strategy *networkPolicyStrategy
+}
+
+// newNetworkPolicyRetryQueue returns a queue that retries failed policies by
+// re-invoking strategy.reconcileSinglePolicy. Call Run to start draining it.
+func newNetworkPolicyRetryQueue(strategy *networkPolicyStrategy) *networkPolicyRetryQueue {
+	return &networkPolicyRetryQueue{
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		strategy: strategy,
+	}
+}
+
+// Enqueue schedules namespace's copy of policyName for retry, recording
+// reason on the networkPolicyRetriesTotal metric.
+func (q *networkPolicyRetryQueue) Enqueue(namespace, policyName string, reason networkPolicyRetryReason) {
+	networkPolicyRetriesTotal.WithLabelValues(namespace, policyName, string(reason)).Inc()
+	q.queue.AddRateLimited(networkPolicyRetryKey{namespace: namespace, policyName: policyName})
+}
+
+// Run drains the queue until ctx is cancelled. It's meant to run as a single
+// background worker for the lifetime of the controller manager.
+func (q *networkPolicyRetryQueue) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		q.queue.ShutDown()
+	}()
+	for q.processNextItem() {
+	}
+}
+
+func (q *networkPolicyRetryQueue) processNextItem() bool {
+	item, shutdown := q.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer q.queue.Done(item)
+
+	key := item.(networkPolicyRetryKey)
+	logger := q.strategy.Log.WithValues("Namespace", key.namespace, "NetworkPolicy", key.policyName)
+
+	if err := q.strategy.reconcileSinglePolicy(key.namespace, key.policyName); err != nil {
+		if q.queue.NumRequeues(key) >= maxNetworkPolicyRetries {
+			logger.Error(err, "giving up on NetworkPolicy after repeated retries")
+			q.queue.Forget(key)
+			return true
+		}
+		logger.Info("retrying NetworkPolicy after propagation error", "error", err.Error())
+		q.queue.AddRateLimited(key)
+		return true
+	}
+
+	q.queue.Forget(key)
+	return true
+}
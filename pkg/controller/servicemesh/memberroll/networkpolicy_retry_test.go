@@ -0,0 +1,77 @@
+package memberroll
+
+import (
+	"context"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/maistra/istio-operator/pkg/controller/common"
+	"github.com/maistra/istio-operator/pkg/controller/common/test"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+func newRetryTestMeshNetworkPolicy(meshNamespace, name string) *networking.NetworkPolicy {
+	return &networking.NetworkPolicy{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: meshNamespace,
+			Labels:    map[string]string{common.OwnerKey: meshNamespace},
+		},
+	}
+}
+
+func TestNetworkPolicyRetryQueueRetriesFailedCreate(t *testing.T) {
+	const meshNamespace = "istio-system"
+	const memberNamespace = "bookinfo"
+
+	meshNetworkPolicy := newRetryTestMeshNetworkPolicy(meshNamespace, "allow-mesh")
+	cl, _ := test.CreateClient(meshNetworkPolicy)
+
+	strategy, err := newNetworkPolicyStrategyWithExclusions(cl, logf.Log, meshNamespace, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error constructing strategy: %v", err)
+	}
+
+	queue := newNetworkPolicyRetryQueue(strategy)
+	queue.Enqueue(memberNamespace, "allow-mesh", retryReasonCreateFailed)
+
+	if !queue.processNextItem() {
+		t.Fatal("expected processNextItem to report more work may remain")
+	}
+
+	copied := &networking.NetworkPolicy{}
+	err = cl.Get(context.TODO(), types.NamespacedName{Namespace: memberNamespace, Name: "allow-mesh"}, copied)
+	if err != nil {
+		t.Fatalf("expected retry to create the NetworkPolicy, got error: %v", err)
+	}
+	if copied.Labels[common.MemberOfKey] != meshNamespace {
+		t.Errorf("expected member-of label %s, got %s", meshNamespace, copied.Labels[common.MemberOfKey])
+	}
+}
+
+func TestNetworkPolicyRetryQueueSkipsNoLongerRequiredPolicy(t *testing.T) {
+	const meshNamespace = "istio-system"
+	const memberNamespace = "bookinfo"
+
+	cl, _ := test.CreateClient()
+
+	strategy, err := newNetworkPolicyStrategyWithExclusions(cl, logf.Log, meshNamespace, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error constructing strategy: %v", err)
+	}
+
+	queue := newNetworkPolicyRetryQueue(strategy)
+	queue.Enqueue(memberNamespace, "no-longer-required", retryReasonDeleteFailed)
+	queue.processNextItem()
+
+	copied := &networking.NetworkPolicy{}
+	err = cl.Get(context.TODO(), types.NamespacedName{Namespace: memberNamespace, Name: "no-longer-required"}, copied)
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected no NetworkPolicy to be created for a no-longer-required policy, got err=%v", err)
+	}
+}
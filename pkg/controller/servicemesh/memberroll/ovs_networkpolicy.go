@@ -2,6 +2,8 @@ package memberroll
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -15,23 +17,88 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// meshGenerationAnnotation records, on a member namespace's copy of a mesh
+	// NetworkPolicy, the metadata.generation of the mesh NetworkPolicy it was
+	// last copied from. A mismatch against the mesh NetworkPolicy's current
+	// generation means the copy is stale and needs to be updated.
+	meshGenerationAnnotation = "maistra.io/mesh-generation"
+
+	// userManagedAnnotation, when set to "true" on a member namespace's copy
+	// of a mesh NetworkPolicy, tells reconcileNamespaceInMesh to leave that
+	// copy alone instead of overwriting user modifications with drift
+	// detection updates.
+	userManagedAnnotation = "maistra.io/user-managed"
+
+	// maxNetworkPolicyUpdateRetries bounds how many update attempts
+	// updateNetworkPolicyFromSource makes, counting the first, before giving
+	// up on repeated conflicts and returning an error.
+	maxNetworkPolicyUpdateRetries = 3
+)
+
 type networkPolicyStrategy struct {
 	common.ControllerResources
-	meshNamespace           string
-	requiredNetworkPolicies sets.String
-	networkPoliciesList     *networking.NetworkPolicyList
+	meshNamespace              string
+	requiredNetworkPolicies    sets.String
+	networkPoliciesList        *networking.NetworkPolicyList
+	preserveResourcesOnRemoval bool
+	excludedNamespaces         sets.String
+	realization                *realizationTracker
+	retryQueue                 *networkPolicyRetryQueue
+}
+
+// SetRealizationTracker installs a tracker that records, per member
+// namespace, which required NetworkPolicies were successfully created and
+// which failed. If unset, realization state is only visible via logging, as
+// before.
+func (s *networkPolicyStrategy) SetRealizationTracker(tracker *realizationTracker) {
+	s.realization = tracker
+}
+
+// SetRetryQueue installs a queue that per-policy create/update/delete
+// failures are enqueued onto for individual retry, instead of only being
+// aggregated into the error reconcileNamespaceInMesh returns. If unset,
+// a failure is only visible via the aggregated error and the next full
+// member-roll reconcile.
+func (s *networkPolicyStrategy) SetRetryQueue(queue *networkPolicyRetryQueue) {
+	s.retryQueue = queue
+}
+
+func (s *networkPolicyStrategy) enqueueRetry(namespace, policyName string, reason networkPolicyRetryReason) {
+	if s.retryQueue != nil {
+		s.retryQueue.Enqueue(namespace, policyName, reason)
+	}
 }
 
 var _ NamespaceReconciler = (*networkPolicyStrategy)(nil)
 
 func newNetworkPolicyStrategy(cl client.Client, baseLogger logr.Logger, meshNamespace string) (*networkPolicyStrategy, error) {
+	return newNetworkPolicyStrategyWithPreservation(cl, baseLogger, meshNamespace, false)
+}
+
+// newNetworkPolicyStrategyWithPreservation is like newNetworkPolicyStrategy, but
+// when preserveResourcesOnRemoval is set, removeNamespaceFromMesh leaves the
+// namespace's mesh NetworkPolicies in place instead of deleting them. This backs
+// spec.preserveResourcesOnDeletion on the ServiceMeshMemberRoll.
+func newNetworkPolicyStrategyWithPreservation(cl client.Client, baseLogger logr.Logger, meshNamespace string, preserveResourcesOnRemoval bool) (*networkPolicyStrategy, error) {
+	return newNetworkPolicyStrategyWithExclusions(cl, baseLogger, meshNamespace, preserveResourcesOnRemoval, nil)
+}
+
+// newNetworkPolicyStrategyWithExclusions is like newNetworkPolicyStrategyWithPreservation,
+// but namespaces named in excludedNamespaces are never reconciled into or removed
+// from the mesh's NetworkPolicies, even if they're later added to (or already
+// part of) the MemberRoll. This backs the operator-wide excluded namespace list
+// used to keep e.g. platform namespaces out of the mesh's network policies.
+func newNetworkPolicyStrategyWithExclusions(cl client.Client, baseLogger logr.Logger, meshNamespace string, preserveResourcesOnRemoval bool, excludedNamespaces []string) (*networkPolicyStrategy, error) {
 	strategy := &networkPolicyStrategy{
 		ControllerResources: common.ControllerResources{
 			Client: cl,
 			Log:    baseLogger.WithValues("NetworkStrategy", "NetworkPolicy"),
 		},
-		meshNamespace:           meshNamespace,
-		requiredNetworkPolicies: sets.NewString(),
+		meshNamespace:              meshNamespace,
+		requiredNetworkPolicies:    sets.NewString(),
+		preserveResourcesOnRemoval: preserveResourcesOnRemoval,
+		excludedNamespaces:         sets.NewString(excludedNamespaces...),
 	}
 	strategy.networkPoliciesList = &networking.NetworkPolicyList{}
 	labelSelector := map[string]string{common.OwnerKey: strategy.meshNamespace}
@@ -52,6 +119,11 @@ func newNetworkPolicyStrategy(cl client.Client, baseLogger logr.Logger, meshName
 func (s *networkPolicyStrategy) reconcileNamespaceInMesh(namespace string) error {
 	logger := s.Log.WithValues("Namespace", namespace)
 
+	if s.excludedNamespaces.Has(namespace) {
+		logger.Info("namespace is in the excluded namespace list; skipping NetworkPolicy reconciliation")
+		return nil
+	}
+
 	namespaceNetworkPolicies := &networking.NetworkPolicyList{}
 	labelSelector := map[string]string{common.MemberOfKey: s.meshNamespace}
 	err := s.Client.List(context.TODO(), client.MatchingLabels(labelSelector).InNamespace(namespace), namespaceNetworkPolicies)
@@ -60,65 +132,142 @@ func (s *networkPolicyStrategy) reconcileNamespaceInMesh(namespace string) error
 		return err
 	}
 
-	allErrors := []error{}
-
-	// add required network policies
 	existingNetworkPolicies := nameSet(namespaceNetworkPolicies)
-	addedNetworkPolicies := sets.NewString()
-	for _, meshNetworkPolicy := range s.networkPoliciesList.Items {
-		networkPolicyName := meshNetworkPolicy.GetName()
-		if !s.requiredNetworkPolicies.Has(networkPolicyName) {
-			// this is not required for members
-			continue
+	allPolicyNames := existingNetworkPolicies.Union(s.requiredNetworkPolicies)
+
+	// reconcile each policy independently: a single Create/Update/Delete
+	// failure is recorded and (if a retry queue is installed) enqueued for
+	// its own retry, rather than aborting the rest of the namespace's
+	// policies or only surfacing via the aggregated error below.
+	allErrors := []error{}
+	for networkPolicyName := range allPolicyNames {
+		if err := s.reconcileSinglePolicy(namespace, networkPolicyName); err != nil {
+			allErrors = append(allErrors, err)
 		}
-		if !existingNetworkPolicies.Has(networkPolicyName) {
-			logger.Info("creating NetworkPolicy", "NetworkPolicy", networkPolicyName)
-			networkPolicy := meshNetworkPolicy.DeepCopy()
-			networkPolicy.ObjectMeta = meta.ObjectMeta{
-				Name:        networkPolicyName,
-				Namespace:   namespace,
-				Labels:      copyMap(meshNetworkPolicy.Labels),
-				Annotations: copyMap(meshNetworkPolicy.Annotations),
-			}
-			common.SetLabel(networkPolicy, common.MemberOfKey, s.meshNamespace)
-			err = s.Client.Create(context.TODO(), networkPolicy)
-			if err == nil {
-				addedNetworkPolicies.Insert(networkPolicyName)
-			} else {
-				logger.Error(err, "error creating NetworkPolicy", "NetworkPolicy", networkPolicyName)
-				allErrors = append(allErrors, err)
-			}
-		} // XXX: else if existingNetworkPolicy.annotations[mesh-generation] != meshNetworkPolicy.annotations[generation] then update?
 	}
 
-	existingNetworkPolicies = existingNetworkPolicies.Union(addedNetworkPolicies)
+	// if there were errors, we've logged them and enqueued what we can for
+	// retry; a following reconcile (or the retry queue) will pick up
+	// whatever's still missing.
+	return utilerrors.NewAggregate(allErrors)
+}
+
+// reconcileSinglePolicy brings policyName's state in namespace in line with
+// whether it's currently required: creating or updating the member
+// namespace's copy of the mesh NetworkPolicy if required, deleting it
+// otherwise. It's used both by reconcileNamespaceInMesh and by
+// networkPolicyRetryQueue so that a single failed policy can be retried
+// without re-running the rest of the namespace's reconciliation.
+func (s *networkPolicyStrategy) reconcileSinglePolicy(namespace, policyName string) error {
+	logger := s.Log.WithValues("Namespace", namespace, "NetworkPolicy", policyName)
 
-	// delete obsolete network policies
-	for networkPolicyName := range existingNetworkPolicies.Difference(s.requiredNetworkPolicies) {
-		logger.Info("deleting NetworkPolicy", "NetworkPolicy", networkPolicyName)
-		networkPolicy := &networking.NetworkPolicy{
-			ObjectMeta: meta.ObjectMeta{
-				Name:      networkPolicyName,
-				Namespace: namespace,
-			},
+	if !s.requiredNetworkPolicies.Has(policyName) {
+		return s.deleteNamespacePolicy(namespace, policyName)
+	}
+
+	var meshNetworkPolicy *networking.NetworkPolicy
+	for i := range s.networkPoliciesList.Items {
+		if s.networkPoliciesList.Items[i].GetName() == policyName {
+			meshNetworkPolicy = &s.networkPoliciesList.Items[i]
+			break
 		}
-		err = s.Client.Delete(context.TODO(), networkPolicy, client.PropagationPolicy(meta.DeletePropagationForeground))
-		if err != nil && !(errors.IsNotFound(err) || errors.IsGone(err)) {
-			logger.Error(err, "error deleting NetworkPolicy", "NetworkPolicy", networkPolicyName)
-			allErrors = append(allErrors, err)
+	}
+	if meshNetworkPolicy == nil {
+		// requiredNetworkPolicies is stale relative to networkPoliciesList
+		// (the mesh NetworkPolicy was removed after this strategy was
+		// constructed); nothing to reconcile against until it's rebuilt.
+		return nil
+	}
+
+	existing := &networking.NetworkPolicy{}
+	err := s.Client.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: policyName}, existing)
+	if errors.IsNotFound(err) {
+		networkPolicy := s.buildNamespaceCopy(namespace, meshNetworkPolicy)
+		logger.Info("creating NetworkPolicy")
+		if err := s.Client.Create(context.TODO(), networkPolicy); err != nil {
+			logger.Error(err, "error creating NetworkPolicy")
+			s.recordPolicyRealization(namespace, policyName, PolicyFailed)
+			s.enqueueRetry(namespace, policyName, retryReasonCreateFailed)
+			return err
 		}
+		s.recordPolicyRealization(namespace, policyName, PolicyRealized)
+		return nil
+	} else if err != nil {
+		logger.Error(err, "error retrieving NetworkPolicy")
+		return err
 	}
 
-	// if there were errors, we've logged them and there's not really anything we can do, as we're in an uncertain state
-	// maybe a following reconcile will add the required network policy that failed.  if it was a delete that failed, we're
-	// just leaving behind some cruft.
-	return utilerrors.NewAggregate(allErrors)
+	if err := s.updateIfDrifted(namespace, existing, meshNetworkPolicy); err != nil {
+		logger.Error(err, "error updating drifted NetworkPolicy")
+		s.recordPolicyRealization(namespace, policyName, PolicyFailed)
+		s.enqueueRetry(namespace, policyName, retryReasonUpdateFailed)
+		return err
+	}
+	s.recordPolicyRealization(namespace, policyName, PolicyRealized)
+	return nil
+}
+
+func (s *networkPolicyStrategy) deleteNamespacePolicy(namespace, policyName string) error {
+	logger := s.Log.WithValues("Namespace", namespace, "NetworkPolicy", policyName)
+	logger.Info("deleting NetworkPolicy")
+	networkPolicy := &networking.NetworkPolicy{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      policyName,
+			Namespace: namespace,
+		},
+	}
+	err := s.Client.Delete(context.TODO(), networkPolicy, client.PropagationPolicy(meta.DeletePropagationForeground))
+	if err != nil && !(errors.IsNotFound(err) || errors.IsGone(err)) {
+		logger.Error(err, "error deleting NetworkPolicy")
+		s.enqueueRetry(namespace, policyName, retryReasonDeleteFailed)
+		return err
+	}
+	return nil
+}
+
+// buildNamespaceCopy builds namespace's copy of meshNetworkPolicy: same spec,
+// labels and annotations, but with its own ObjectMeta and a fresh
+// maistra.io/mesh-generation stamp.
+func (s *networkPolicyStrategy) buildNamespaceCopy(namespace string, meshNetworkPolicy *networking.NetworkPolicy) *networking.NetworkPolicy {
+	networkPolicy := meshNetworkPolicy.DeepCopy()
+	networkPolicy.ObjectMeta = meta.ObjectMeta{
+		Name:        meshNetworkPolicy.GetName(),
+		Namespace:   namespace,
+		Labels:      copyMap(meshNetworkPolicy.Labels),
+		Annotations: copyMap(meshNetworkPolicy.Annotations),
+	}
+	common.SetLabel(networkPolicy, common.MemberOfKey, s.meshNamespace)
+	stampMeshGeneration(networkPolicy, meshNetworkPolicy.GetGeneration())
+	return networkPolicy
+}
+
+// recordPolicyRealization is a nil-safe wrapper around
+// realizationTracker.recordPolicy, since a realization tracker is optional.
+func (s *networkPolicyStrategy) recordPolicyRealization(namespace, policyName string, state PolicyRealizationState) {
+	if s.realization != nil {
+		s.realization.recordPolicy(namespace, policyName, state)
+	}
 }
 
 func (s *networkPolicyStrategy) removeNamespaceFromMesh(namespace string) error {
-	allErrors := []error{}
 	logger := s.Log.WithValues("Namespace", namespace)
 
+	if s.preserveResourcesOnRemoval {
+		logger.Info("preserveResourcesOnDeletion is set; leaving mesh NetworkPolicies in namespace")
+		return nil
+	}
+
+	if s.excludedNamespaces.Has(namespace) {
+		logger.Info("namespace is in the excluded namespace list; it was never reconciled into the mesh, skipping removal")
+		return nil
+	}
+
+	if s.realization != nil {
+		defer s.realization.forgetNamespace(namespace)
+	}
+
+	allErrors := []error{}
+
 	npList := &networking.NetworkPolicyList{}
 	labelSelector := map[string]string{common.MemberOfKey: s.meshNamespace}
 	err := s.Client.List(context.TODO(), client.MatchingLabels(labelSelector).InNamespace(namespace), npList)
@@ -138,6 +287,61 @@ func (s *networkPolicyStrategy) removeNamespaceFromMesh(namespace string) error
 	return utilerrors.NewAggregate(allErrors)
 }
 
+func stampMeshGeneration(networkPolicy *networking.NetworkPolicy, generation int64) {
+	if networkPolicy.Annotations == nil {
+		networkPolicy.Annotations = map[string]string{}
+	}
+	networkPolicy.Annotations[meshGenerationAnnotation] = strconv.FormatInt(generation, 10)
+}
+
+// updateIfDrifted brings existing (a member namespace's copy of a mesh
+// NetworkPolicy) back in line with source (the mesh NetworkPolicy itself)
+// when source's generation has moved on since existing was last written.
+// Copies annotated maistra.io/user-managed=true are left untouched, so
+// operators can take over a copy without reconcile fighting them for it.
+func (s *networkPolicyStrategy) updateIfDrifted(namespace string, existing, source *networking.NetworkPolicy) error {
+	if existing.Annotations[userManagedAnnotation] == "true" {
+		return nil
+	}
+	if existing.Annotations[meshGenerationAnnotation] == strconv.FormatInt(source.GetGeneration(), 10) {
+		return nil
+	}
+	return s.updateNetworkPolicyFromSource(namespace, existing, source)
+}
+
+// updateNetworkPolicyFromSource updates existing's Spec/Labels/Annotations to
+// match source, retrying with a freshly-fetched copy if the update conflicts
+// with a concurrent write.
+func (s *networkPolicyStrategy) updateNetworkPolicyFromSource(namespace string, existing, source *networking.NetworkPolicy) error {
+	logger := s.Log.WithValues("Namespace", namespace, "NetworkPolicy", existing.GetName())
+
+	for attempt := 0; attempt < maxNetworkPolicyUpdateRetries; attempt++ {
+		updated := existing.DeepCopy()
+		updated.Spec = *source.Spec.DeepCopy()
+		updated.Labels = copyMap(source.Labels)
+		updated.Annotations = copyMap(source.Annotations)
+		common.SetLabel(updated, common.MemberOfKey, s.meshNamespace)
+		stampMeshGeneration(updated, source.GetGeneration())
+
+		logger.Info("updating drifted NetworkPolicy")
+		err := s.Client.Update(context.TODO(), updated)
+		if err == nil {
+			return nil
+		}
+		if !errors.IsConflict(err) {
+			return err
+		}
+
+		logger.Info("update conflicted with a concurrent write; retrying with a fresh copy")
+		fresh := &networking.NetworkPolicy{}
+		if getErr := s.Client.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: existing.GetName()}, fresh); getErr != nil {
+			return getErr
+		}
+		existing = fresh
+	}
+	return fmt.Errorf("exceeded %d retries updating NetworkPolicy %s/%s after conflicts", maxNetworkPolicyUpdateRetries, namespace, existing.GetName())
+}
+
 func copyMap(in map[string]string) map[string]string {
 	out := make(map[string]string, len(in))
 	for key, val := range in {
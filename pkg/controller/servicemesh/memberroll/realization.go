@@ -0,0 +1,105 @@
+package memberroll
+
+import "sync"
+
+// PolicyRealizationState describes whether a required mesh NetworkPolicy has
+// actually been created in a member namespace.
+type PolicyRealizationState string
+
+const (
+	PolicyRealized PolicyRealizationState = "Realized"
+	PolicyFailed   PolicyRealizationState = "Failed"
+)
+
+// NamespacePolicyStatus is the realization state of all of a mesh's required
+// NetworkPolicies within a single member namespace.
+type NamespacePolicyStatus struct {
+	Realized []string
+	Failed   []string
+}
+
+// realizationTracker accumulates per-namespace, per-policy realization state
+// recorded by networkPolicyStrategy as it reconciles each member namespace.
+// Its state is read through Snapshot and Counters; nothing writes it into
+// the ServiceMeshMemberRoll status or sets a condition from it.
+type realizationTracker struct {
+	mu     sync.Mutex
+	status map[string]NamespacePolicyStatus
+}
+
+// newRealizationTracker returns an empty realizationTracker.
+func newRealizationTracker() *realizationTracker {
+	return &realizationTracker{status: map[string]NamespacePolicyStatus{}}
+}
+
+// recordPolicy records the realization state of policyName in namespace,
+// replacing whatever was previously recorded for that namespace+policy pair.
+func (t *realizationTracker) recordPolicy(namespace, policyName string, state PolicyRealizationState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ns := t.status[namespace]
+	ns.Realized = removeString(ns.Realized, policyName)
+	ns.Failed = removeString(ns.Failed, policyName)
+	switch state {
+	case PolicyRealized:
+		ns.Realized = append(ns.Realized, policyName)
+	case PolicyFailed:
+		ns.Failed = append(ns.Failed, policyName)
+	}
+	t.status[namespace] = ns
+}
+
+// forgetNamespace drops all recorded state for namespace, used when a
+// namespace is removed from the mesh.
+func (t *realizationTracker) forgetNamespace(namespace string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.status, namespace)
+}
+
+// Snapshot returns a copy of the current per-namespace realization status.
+func (t *realizationTracker) Snapshot() map[string]NamespacePolicyStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := make(map[string]NamespacePolicyStatus, len(t.status))
+	for namespace, ns := range t.status {
+		snapshot[namespace] = NamespacePolicyStatus{
+			Realized: append([]string{}, ns.Realized...),
+			Failed:   append([]string{}, ns.Failed...),
+		}
+	}
+	return snapshot
+}
+
+// Counters returns the desired, realized and failed namespace counts:
+// desired is the number of namespaces tracked, realized is the number with no
+// failed policies and at least one realized policy, failed is the number
+// with at least one failed policy.
+func (t *realizationTracker) Counters() (desired, realized, failed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	desired = len(t.status)
+	for _, ns := range t.status {
+		if len(ns.Failed) > 0 {
+			failed++
+			continue
+		}
+		if len(ns.Realized) > 0 {
+			realized++
+		}
+	}
+	return desired, realized, failed
+}
+
+func removeString(list []string, value string) []string {
+	out := list[:0:0]
+	for _, v := range list {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
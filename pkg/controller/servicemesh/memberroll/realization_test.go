@@ -0,0 +1,64 @@
+package memberroll
+
+import "testing"
+
+func TestRealizationTrackerRecordAndSnapshot(t *testing.T) {
+	tracker := newRealizationTracker()
+	tracker.recordPolicy("bookinfo", "allow-mesh", PolicyRealized)
+	tracker.recordPolicy("bookinfo", "allow-ingress", PolicyFailed)
+
+	snapshot := tracker.Snapshot()
+	ns, ok := snapshot["bookinfo"]
+	if !ok {
+		t.Fatal("expected a status entry for namespace bookinfo")
+	}
+	if len(ns.Realized) != 1 || ns.Realized[0] != "allow-mesh" {
+		t.Errorf("unexpected realized policies: %v", ns.Realized)
+	}
+	if len(ns.Failed) != 1 || ns.Failed[0] != "allow-ingress" {
+		t.Errorf("unexpected failed policies: %v", ns.Failed)
+	}
+}
+
+func TestRealizationTrackerRecordPolicyReplacesPreviousState(t *testing.T) {
+	tracker := newRealizationTracker()
+	tracker.recordPolicy("bookinfo", "allow-mesh", PolicyFailed)
+	tracker.recordPolicy("bookinfo", "allow-mesh", PolicyRealized)
+
+	ns := tracker.Snapshot()["bookinfo"]
+	if len(ns.Failed) != 0 {
+		t.Errorf("expected no failed policies after re-recording as realized, got %v", ns.Failed)
+	}
+	if len(ns.Realized) != 1 || ns.Realized[0] != "allow-mesh" {
+		t.Errorf("expected allow-mesh to be realized, got %v", ns.Realized)
+	}
+}
+
+func TestRealizationTrackerCounters(t *testing.T) {
+	tracker := newRealizationTracker()
+	tracker.recordPolicy("bookinfo", "allow-mesh", PolicyRealized)
+	tracker.recordPolicy("istio-system", "allow-mesh", PolicyFailed)
+	tracker.recordPolicy("other", "allow-mesh", PolicyRealized)
+	tracker.recordPolicy("other", "allow-ingress", PolicyFailed)
+
+	desired, realized, failed := tracker.Counters()
+	if desired != 3 {
+		t.Errorf("expected 3 desired namespaces, got %d", desired)
+	}
+	if realized != 1 {
+		t.Errorf("expected 1 fully realized namespace, got %d", realized)
+	}
+	if failed != 2 {
+		t.Errorf("expected 2 namespaces with failures, got %d", failed)
+	}
+}
+
+func TestRealizationTrackerForgetNamespace(t *testing.T) {
+	tracker := newRealizationTracker()
+	tracker.recordPolicy("bookinfo", "allow-mesh", PolicyRealized)
+	tracker.forgetNamespace("bookinfo")
+
+	if _, ok := tracker.Snapshot()["bookinfo"]; ok {
+		t.Error("expected namespace to be forgotten")
+	}
+}